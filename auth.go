@@ -0,0 +1,156 @@
+//
+// Copyright 2016 James McGuire
+//
+// This code is covered under the MIT License
+// Please refer to the LICENSE file in the root of this
+// repository for any information.
+
+package mediawiki
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/hex"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Authenticator adds credentials to every HTTP request sent by MWApi,
+// including uploads and file downloads.
+type Authenticator interface {
+	// Authenticate is called for every request before it is sent. form
+	// holds the parameters of url-encoded POST bodies, which are part of
+	// OAuth 1.0a signatures. It is nil for multipart and GET requests.
+	Authenticate(request *http.Request, form url.Values) error
+}
+
+// BasicAuth authenticates with HTTP basic authentication, for wikis that
+// sit behind a password protected web server.
+type BasicAuth struct {
+	Username string
+	Password string
+}
+
+// Authenticate implements Authenticator.
+func (a *BasicAuth) Authenticate(request *http.Request, form url.Values) error {
+	request.SetBasicAuth(a.Username, a.Password)
+	return nil
+}
+
+// OAuth2 authenticates with an OAuth 2 access token, such as the one
+// issued to an owner-only consumer.
+//
+// See https://www.mediawiki.org/wiki/OAuth/Owner-only_consumers
+type OAuth2 struct {
+	AccessToken string
+}
+
+// Authenticate implements Authenticator.
+func (a *OAuth2) Authenticate(request *http.Request, form url.Values) error {
+	request.Header.Set("Authorization", "Bearer "+a.AccessToken)
+	return nil
+}
+
+// OAuth1 signs requests with OAuth 1.0a using HMAC-SHA1, as used by
+// owner-only consumers registered with the 1.0a protocol.
+//
+// See https://www.mediawiki.org/wiki/OAuth/Owner-only_consumers
+type OAuth1 struct {
+	ConsumerKey    string
+	ConsumerSecret string
+	AccessToken    string
+	AccessSecret   string
+}
+
+// Authenticate implements Authenticator.
+func (a *OAuth1) Authenticate(request *http.Request, form url.Values) error {
+	nonce := make([]byte, 16)
+	_, err := rand.Read(nonce)
+	if err != nil {
+		return err
+	}
+
+	oauth := a.params(hex.EncodeToString(nonce), strconv.FormatInt(time.Now().Unix(), 10))
+	oauth.Set("oauth_signature", a.signature(request.Method, request.URL, form, oauth))
+
+	keys := make([]string, 0, len(oauth))
+	for key := range oauth {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	parts := make([]string, 0, len(keys))
+	for _, key := range keys {
+		parts = append(parts, oauthEscape(key)+`="`+oauthEscape(oauth.Get(key))+`"`)
+	}
+	request.Header.Set("Authorization", "OAuth "+strings.Join(parts, ", "))
+	return nil
+}
+
+// The oauth_* protocol parameters, without the signature.
+func (a *OAuth1) params(nonce, timestamp string) url.Values {
+	return url.Values{
+		"oauth_consumer_key":     {a.ConsumerKey},
+		"oauth_token":            {a.AccessToken},
+		"oauth_signature_method": {"HMAC-SHA1"},
+		"oauth_timestamp":        {timestamp},
+		"oauth_nonce":            {nonce},
+		"oauth_version":          {"1.0"},
+	}
+}
+
+// Compute the signature as described in RFC 5849 section 3.4.
+func (a *OAuth1) signature(method string, u *url.URL, form, oauth url.Values) string {
+	var params []string
+	add := func(values url.Values) {
+		for key, list := range values {
+			for _, value := range list {
+				params = append(params, oauthEscape(key)+"="+oauthEscape(value))
+			}
+		}
+	}
+	add(u.Query())
+	add(form)
+	add(oauth)
+	sort.Strings(params)
+
+	text := strings.ToUpper(method) + "&" + oauthEscape(oauthBaseURI(u)) + "&" + oauthEscape(strings.Join(params, "&"))
+	key := oauthEscape(a.ConsumerSecret) + "&" + oauthEscape(a.AccessSecret)
+	mac := hmac.New(sha1.New, []byte(key))
+	mac.Write([]byte(text))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// The base string URI from RFC 5849 section 3.4.1.2. The path is used as it
+// was sent, so it must not be escaped again.
+func oauthBaseURI(u *url.URL) string {
+	scheme, host := strings.ToLower(u.Scheme), strings.ToLower(u.Host)
+	if (scheme == "http" && u.Port() == "80") || (scheme == "https" && u.Port() == "443") {
+		host = strings.ToLower(u.Hostname())
+	}
+	return scheme + "://" + host + u.EscapedPath()
+}
+
+// Percent encode s as required by RFC 5849 section 3.6: everything except
+// unreserved characters is encoded, with upper case hex digits.
+func oauthEscape(s string) string {
+	const hexDigits = "0123456789ABCDEF"
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if ('A' <= c && c <= 'Z') || ('a' <= c && c <= 'z') || ('0' <= c && c <= '9') ||
+			c == '-' || c == '.' || c == '_' || c == '~' {
+			b.WriteByte(c)
+			continue
+		}
+		b.WriteByte('%')
+		b.WriteByte(hexDigits[c>>4])
+		b.WriteByte(hexDigits[c&15])
+	}
+	return b.String()
+}
@@ -0,0 +1,105 @@
+package mediawiki
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestOAuth1Signature(t *testing.T) {
+	// Example from https://developer.twitter.com/en/docs/authentication/oauth-1-0a/creating-a-signature
+	auth := &OAuth1{
+		ConsumerKey:    "xvz1evFS4wEEPTGEFPHBog",
+		ConsumerSecret: "kAcSOqF21Fu85e7zjz7ZN2U4ZRhfV3WpwPAoE3Z7kBw",
+		AccessToken:    "370773112-GmHxMAgYyLbNEtIKZeRNFsMKPR9EyMZeS9weJAEb",
+		AccessSecret:   "LswwdoUaIvS8ltyTt5jkRh4J50vUPVVHtR2YPi5kE",
+	}
+	u, err := url.Parse("https://api.twitter.com/1.1/statuses/update.json?include_entities=true")
+	if err != nil {
+		t.Fatal(err)
+	}
+	form := url.Values{"status": {"Hello Ladies + Gentlemen, a signed OAuth request!"}}
+	oauth := auth.params("kYjzVBB8Y0ZFabxSWbWovY3uYSQ2pTgmZeNu2VS4cg", "1318622958")
+
+	signature := auth.signature("POST", u, form, oauth)
+	if signature != "hCtSmYh+iHYCEqBWrE7C7hYmtUk=" {
+		t.Errorf("Wrong signature: %s", signature)
+	}
+}
+
+func TestOAuth1BaseURI(t *testing.T) {
+	tests := map[string]string{
+		"https://Example.COM:443/w/api.php?action=query": "https://example.com/w/api.php",
+		"http://example.com:8080/w/a%20b/api.php":        "http://example.com:8080/w/a%20b/api.php",
+		"http://example.com/wiki/%C3%A9t%C3%A9/api.php":  "http://example.com/wiki/%C3%A9t%C3%A9/api.php",
+	}
+	for raw, expected := range tests {
+		u, err := url.Parse(raw)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if base := oauthBaseURI(u); base != expected {
+			t.Errorf("Base URI of %s is %s, expected %s", raw, base, expected)
+		}
+	}
+}
+
+func TestOAuth1Header(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header := r.Header.Get("Authorization")
+		for _, param := range []string{`oauth_consumer_key="key"`, `oauth_token="token"`, `oauth_signature_method="HMAC-SHA1"`, `oauth_signature="`, `oauth_nonce="`} {
+			if !strings.Contains(header, param) {
+				t.Errorf("Authorization header is missing %s: %s", param, header)
+			}
+		}
+		fmt.Fprintln(w, `{"status":"PASS"}`)
+	}))
+	defer ts.Close()
+	client, err := New(ts.URL, "TESTING")
+	if err != nil {
+		t.Fatalf("Error creating client: %s", err)
+	}
+	client.Auth = &OAuth1{ConsumerKey: "key", ConsumerSecret: "secret", AccessToken: "token", AccessSecret: "secret"}
+	_, err = client.API(map[string]string{"action": "query"})
+	if err != nil {
+		t.Fatalf("API() returned an error: %s", err)
+	}
+}
+
+func TestOAuth2(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer ACCESS" {
+			t.Errorf("Wrong authorization header: %s", r.Header.Get("Authorization"))
+		}
+		if r.Method == "POST" && r.Header.Get("Content-Type") == "application/x-www-form-urlencoded" {
+			r.ParseForm()
+			fmt.Fprintln(w, fmt.Sprintf(fileURL, r.Form.Get("titles")))
+			return
+		}
+		if r.Method == "POST" {
+			fmt.Fprintln(w, `{"upload":{"result":"Success"}}`)
+			return
+		}
+		fmt.Fprint(w, "THINGS")
+	}))
+	defer ts.Close()
+	client, err := New(ts.URL, "TESTING")
+	if err != nil {
+		t.Fatalf("Error creating client: %s", err)
+	}
+	client.Auth = &OAuth2{AccessToken: "ACCESS"}
+	client.tokens[CSRFToken] = "asdf"
+
+	err = client.Upload("stuff", strings.NewReader("stuff"))
+	if err != nil {
+		t.Fatalf("Error uploading: %s", err)
+	}
+	file, err := client.Download(ts.URL)
+	if err != nil {
+		t.Fatalf("Error downloading: %s", err)
+	}
+	file.Close()
+}
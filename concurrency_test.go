@@ -0,0 +1,109 @@
+package mediawiki
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestConcurrentUse(t *testing.T) {
+	var tokenRequests int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/") {
+			fmt.Fprintln(w, `{"upload":{"result":"Success"}}`)
+			return
+		}
+		err := r.ParseForm()
+		if err != nil {
+			panic(err)
+		}
+		switch {
+		case r.Form.Get("meta") == "tokens":
+			atomic.AddInt32(&tokenRequests, 1)
+			fmt.Fprintln(w, editTokenReponse)
+		case r.Form.Get("action") == "edit":
+			fmt.Fprintln(w, editsuccess)
+		default:
+			fmt.Fprintln(w, readPage)
+		}
+	}))
+	defer ts.Close()
+	client, err := New(ts.URL, "TESTING")
+	if err != nil {
+		t.Fatalf("Error creating client: %s", err)
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 40)
+	for i := 0; i < 10; i++ {
+		wg.Add(4)
+		go func() {
+			defer wg.Done()
+			errs <- client.Edit(map[string]string{"title": "somepage", "text": "stuff"})
+		}()
+		go func() {
+			defer wg.Done()
+			_, err := client.Read("somepage")
+			errs <- err
+		}()
+		go func() {
+			defer wg.Done()
+			errs <- client.Upload("stuff", strings.NewReader("stuff"))
+		}()
+		go func() {
+			defer wg.Done()
+			errs <- client.GetEditToken()
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Errorf("Concurrent request failed: %s", err)
+		}
+	}
+	if atomic.LoadInt32(&tokenRequests) == 0 {
+		t.Error("No tokens were requested")
+	}
+}
+
+func TestConcurrentBadToken(t *testing.T) {
+	var tokens int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		err := r.ParseForm()
+		if err != nil {
+			panic(err)
+		}
+		if r.Form.Get("meta") == "tokens" {
+			fmt.Fprintf(w, `{"query":{"tokens":{"csrftoken":"token-%d"}}}`, atomic.AddInt32(&tokens, 1))
+			return
+		}
+		if r.Form.Get("token") == "STALE" {
+			fmt.Fprintln(w, badtoken)
+			return
+		}
+		fmt.Fprintln(w, editsuccess)
+	}))
+	defer ts.Close()
+	client, err := New(ts.URL, "TESTING")
+	if err != nil {
+		t.Fatalf("Error creating client: %s", err)
+	}
+	client.tokens[CSRFToken] = "STALE"
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := client.Edit(map[string]string{"title": "somepage"}); err != nil {
+				t.Errorf("Edit failed: %s", err)
+			}
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,199 @@
+//
+// Copyright 2016 James McGuire
+//
+// This code is covered under the MIT License
+// Please refer to the LICENSE file in the root of this
+// repository for any information.
+
+package mediawiki
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"hash"
+	"io"
+	"net/http"
+	"strconv"
+)
+
+// ErrChecksumMismatch is returned when reading a downloaded file that
+// doesn't match the SHA-1 hash the wiki has for it.
+var ErrChecksumMismatch = errors.New("downloaded file doesn't match its SHA-1 hash")
+
+// DownloadOptions configures DownloadFile.
+type DownloadOptions struct {
+	// Width and Height, if set, download a thumbnail scaled to fit them
+	// instead of the original file.
+	Width  int
+	Height int
+	// VerifySHA1 checks the original file against the SHA-1 hash the wiki
+	// has for it. Reading the end of the file fails with
+	// ErrChecksumMismatch if it doesn't match. Thumbnails can't be
+	// verified.
+	VerifySHA1 bool
+	// Offset resumes a partial download, skipping the first Offset bytes
+	// with an HTTP Range request. To verify the SHA-1 hash of a resumed
+	// download, Partial must hold the bytes that were already downloaded.
+	Offset  int64
+	Partial io.Reader
+}
+
+// Download a file.
+//
+// Returns a readcloser that must be closed manually. Refer to the
+// example app for additional usage.
+func (m *MWApi) Download(filename string) (io.ReadCloser, error) {
+	return m.DownloadContext(context.Background(), filename)
+}
+
+// DownloadContext is like Download but uses ctx for the file lookup and the
+// download.
+func (m *MWApi) DownloadContext(ctx context.Context, filename string) (io.ReadCloser, error) {
+	return m.DownloadFileContext(ctx, filename, nil)
+}
+
+// DownloadFile is like Download but can fetch thumbnails, verify the file
+// and resume partial downloads. opts may be nil to use the defaults.
+//
+// If the file doesn't exist the error satisfies
+// errors.Is(err, ErrMissingTitle), and a response with a non-2xx status is
+// returned as an *APIError that satisfies errors.Is(err, ErrHTTP).
+func (m *MWApi) DownloadFile(filename string, opts *DownloadOptions) (io.ReadCloser, error) {
+	return m.DownloadFileContext(context.Background(), filename, opts)
+}
+
+// DownloadFileContext is like DownloadFile but uses ctx for the file lookup
+// and the download.
+func (m *MWApi) DownloadFileContext(ctx context.Context, filename string, opts *DownloadOptions) (io.ReadCloser, error) {
+	if opts == nil {
+		opts = &DownloadOptions{}
+	}
+	thumbnail := opts.Width > 0 || opts.Height > 0
+	if opts.VerifySHA1 && thumbnail {
+		return nil, errors.New("thumbnails can't be verified")
+	}
+	if opts.VerifySHA1 && opts.Offset > 0 && opts.Partial == nil {
+		return nil, errors.New("verifying a resumed download needs the partial file")
+	}
+
+	// First get the direct url of the file
+	query := map[string]string{
+		"action": "query",
+		"prop":   "imageinfo",
+		"iiprop": "url|sha1",
+		"titles": filename,
+	}
+	if opts.Width > 0 {
+		query["iiurlwidth"] = strconv.Itoa(opts.Width)
+	}
+	if opts.Height > 0 {
+		query["iiurlheight"] = strconv.Itoa(opts.Height)
+	}
+
+	body, err := m.APIContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	var response Response
+	err = json.Unmarshal(body, &response)
+	if err != nil {
+		return nil, err
+	}
+	pl := response.PageSlice()
+
+	if len(pl) < 1 {
+		return nil, missingFile(filename)
+	}
+	page := pl[0]
+	if len(page.Imageinfo) < 1 {
+		return nil, missingFile(filename)
+	}
+	info := page.Imageinfo[0]
+	fileurl := info.Url
+	if thumbnail {
+		if info.ThumbUrl == "" {
+			return nil, errors.New("no thumbnail returned")
+		}
+		fileurl = info.ThumbUrl
+	}
+
+	var digest hash.Hash
+	if opts.VerifySHA1 {
+		digest = sha1.New()
+		if opts.Offset > 0 {
+			_, err = io.CopyN(digest, opts.Partial, opts.Offset)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// Then return the body of the response
+	request, err := http.NewRequestWithContext(ctx, "GET", fileurl, nil)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Offset > 0 {
+		request.Header.Set("Range", "bytes="+strconv.FormatInt(opts.Offset, 10)+"-")
+	}
+	err = m.authenticate(request, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := m.client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		resp.Body.Close()
+		return nil, &APIError{
+			Code:       ErrHTTP.Code,
+			Info:       http.StatusText(resp.StatusCode),
+			HTTPStatus: resp.StatusCode,
+		}
+	}
+	// Servers that don't support ranges send the whole file.
+	if opts.Offset > 0 && resp.StatusCode != http.StatusPartialContent {
+		err = skip(resp.Body, opts.Offset)
+		if err != nil {
+			resp.Body.Close()
+			return nil, err
+		}
+	}
+
+	if digest == nil {
+		return resp.Body, nil
+	}
+	return &verifyingReader{body: resp.Body, digest: digest, sha1: info.Sha1}, nil
+}
+
+// The error returned when there is no file called filename.
+func missingFile(filename string) error {
+	return &APIError{Code: ErrMissingTitle.Code, Info: "There is no file called " + filename, Module: "query"}
+}
+
+// Hash a download as it is read and compare it to the expected hash at the
+// end.
+type verifyingReader struct {
+	body   io.ReadCloser
+	digest hash.Hash
+	sha1   string
+}
+
+func (v *verifyingReader) Read(b []byte) (int, error) {
+	n, err := v.body.Read(b)
+	v.digest.Write(b[:n])
+	if err == io.EOF && hex.EncodeToString(v.digest.Sum(nil)) != v.sha1 {
+		return n, ErrChecksumMismatch
+	}
+	return n, err
+}
+
+func (v *verifyingReader) Close() error {
+	return v.body.Close()
+}
@@ -0,0 +1,174 @@
+package mediawiki
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+const downloadFile = "abcdefghijklmnopqrstuvwxyz"
+
+// A wiki serving downloadFile and a thumbnail of it. Range requests are
+// ignored unless ranges is set.
+func downloadServer(t *testing.T, checksum string, ranges bool) *httptest.Server {
+	var ts *httptest.Server
+	ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/file":
+			if rng := r.Header.Get("Range"); ranges && rng != "" {
+				offset, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(rng, "bytes="), "-"))
+				if err != nil {
+					t.Errorf("Bad range: %s", rng)
+				}
+				w.WriteHeader(http.StatusPartialContent)
+				fmt.Fprint(w, downloadFile[offset:])
+				return
+			}
+			fmt.Fprint(w, downloadFile)
+			return
+		case "/thumb":
+			fmt.Fprint(w, "THUMBNAIL")
+			return
+		case "/missing":
+			http.NotFound(w, r)
+			return
+		}
+
+		err := r.ParseForm()
+		if err != nil {
+			panic(err)
+		}
+		if r.Form.Get("iiprop") != "url|sha1" {
+			t.Errorf("Bad imageinfo request: %v", r.Form)
+		}
+		path := "/file"
+		if r.Form.Get("titles") == "File:Missing.txt" {
+			path = "/missing"
+		}
+		thumb := ""
+		if r.Form.Get("iiurlwidth") == "120" {
+			thumb = fmt.Sprintf(`,"thumburl":"%s/thumb","thumbwidth":120,"thumbheight":90`, ts.URL)
+		}
+		fmt.Fprintf(w, `{"query":{"pages":{"107":{"pageid":107,"ns":6,"title":"File:Alphabet.txt","imageinfo":[{"url":"%s%s","descriptionurl":"TEST","sha1":"%s"%s}]}}}}`,
+			ts.URL, path, checksum, thumb)
+	}))
+	return ts
+}
+
+func alphabetSHA1() string {
+	sum := sha1.Sum([]byte(downloadFile))
+	return hex.EncodeToString(sum[:])
+}
+
+func TestDownloadFileStatus(t *testing.T) {
+	ts := downloadServer(t, alphabetSHA1(), false)
+	defer ts.Close()
+	client, err := New(ts.URL, "TESTING")
+	if err != nil {
+		t.Fatalf("Error creating client: %s", err)
+	}
+
+	_, err = client.Download("File:Missing.txt")
+	if !errors.Is(err, ErrHTTP) {
+		t.Fatalf("Expected an HTTP error, got %v", err)
+	}
+	if err.(*APIError).HTTPStatus != http.StatusNotFound {
+		t.Errorf("Wrong status: %d", err.(*APIError).HTTPStatus)
+	}
+}
+
+func TestDownloadFileVerify(t *testing.T) {
+	ts := downloadServer(t, alphabetSHA1(), false)
+	defer ts.Close()
+	client, err := New(ts.URL, "TESTING")
+	if err != nil {
+		t.Fatalf("Error creating client: %s", err)
+	}
+
+	file, err := client.DownloadFile("File:Alphabet.txt", &DownloadOptions{VerifySHA1: true})
+	if err != nil {
+		t.Fatalf("Error downloading file: %s", err)
+	}
+	defer file.Close()
+	contents, err := ioutil.ReadAll(file)
+	if err != nil {
+		t.Fatalf("Error reading verified file: %s", err)
+	}
+	if string(contents) != downloadFile {
+		t.Errorf("Wrong file contents: %s", contents)
+	}
+}
+
+func TestDownloadFileChecksumMismatch(t *testing.T) {
+	ts := downloadServer(t, "0000000000000000000000000000000000000000", false)
+	defer ts.Close()
+	client, err := New(ts.URL, "TESTING")
+	if err != nil {
+		t.Fatalf("Error creating client: %s", err)
+	}
+
+	file, err := client.DownloadFile("File:Alphabet.txt", &DownloadOptions{VerifySHA1: true})
+	if err != nil {
+		t.Fatalf("Error downloading file: %s", err)
+	}
+	defer file.Close()
+	_, err = ioutil.ReadAll(file)
+	if err != ErrChecksumMismatch {
+		t.Fatalf("Expected a checksum mismatch, got %v", err)
+	}
+}
+
+func TestDownloadFileThumbnail(t *testing.T) {
+	ts := downloadServer(t, alphabetSHA1(), false)
+	defer ts.Close()
+	client, err := New(ts.URL, "TESTING")
+	if err != nil {
+		t.Fatalf("Error creating client: %s", err)
+	}
+
+	file, err := client.DownloadFile("File:Alphabet.txt", &DownloadOptions{Width: 120})
+	if err != nil {
+		t.Fatalf("Error downloading thumbnail: %s", err)
+	}
+	defer file.Close()
+	contents, _ := ioutil.ReadAll(file)
+	if string(contents) != "THUMBNAIL" {
+		t.Errorf("Wrong thumbnail contents: %s", contents)
+	}
+}
+
+func TestDownloadFileResume(t *testing.T) {
+	for _, ranges := range []bool{true, false} {
+		ts := downloadServer(t, alphabetSHA1(), ranges)
+		client, err := New(ts.URL, "TESTING")
+		if err != nil {
+			t.Fatalf("Error creating client: %s", err)
+		}
+
+		opts := &DownloadOptions{
+			VerifySHA1: true,
+			Offset:     10,
+			Partial:    strings.NewReader(downloadFile[:10]),
+		}
+		file, err := client.DownloadFile("File:Alphabet.txt", opts)
+		if err != nil {
+			t.Fatalf("Error resuming download: %s", err)
+		}
+		contents, err := ioutil.ReadAll(file)
+		if err != nil {
+			t.Fatalf("Error reading resumed download (ranges %t): %s", ranges, err)
+		}
+		if string(contents) != downloadFile[10:] {
+			t.Errorf("Wrong rest of file (ranges %t): %s", ranges, contents)
+		}
+		file.Close()
+		ts.Close()
+	}
+}
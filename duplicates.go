@@ -0,0 +1,174 @@
+//
+// Copyright 2016 James McGuire
+//
+// This code is covered under the MIT License
+// Please refer to the LICENSE file in the root of this
+// repository for any information.
+
+package mediawiki
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+	"time"
+)
+
+// Values for UploadRequest.OnDuplicate.
+const (
+	// DuplicateUpload uploads the file even if a copy already exists.
+	DuplicateUpload = ""
+	// DuplicateSkip doesn't upload the file if a copy already exists.
+	DuplicateSkip = "skip"
+	// DuplicateRedirect doesn't upload the file if a copy already exists
+	// but creates a redirect from the requested name to the copy.
+	DuplicateRedirect = "redirect"
+)
+
+// Duplicate is a file on the wiki with the same contents as a local file.
+type Duplicate struct {
+	// Name is the name of the file without the File: prefix, with
+	// underscores instead of spaces.
+	Name      string
+	Timestamp time.Time
+	// Deleted is set for deleted files, which are only listed if the
+	// user has the deletedhistory right.
+	Deleted bool
+}
+
+// Unmarshal list=allimages and list=filearchive responses...
+type duplicatesResponse struct {
+	Query struct {
+		AllImages   []Duplicate
+		FileArchive []Duplicate
+	}
+}
+
+// FindDuplicates reads file to the end and returns the files on the wiki
+// with the same SHA-1 hash, including deleted files if the user is allowed
+// to see them.
+func (m *MWApi) FindDuplicates(file io.Reader) ([]Duplicate, error) {
+	return m.FindDuplicatesContext(context.Background(), file)
+}
+
+// FindDuplicatesContext is like FindDuplicates but uses ctx for the
+// queries.
+func (m *MWApi) FindDuplicatesContext(ctx context.Context, file io.Reader) ([]Duplicate, error) {
+	hash := sha1.New()
+	_, err := io.Copy(hash, file)
+	if err != nil {
+		return nil, err
+	}
+	return m.FindDuplicatesSHA1Context(ctx, hex.EncodeToString(hash.Sum(nil)))
+}
+
+// FindDuplicatesSHA1 is like FindDuplicates but takes the hex encoded SHA-1
+// hash of the file.
+func (m *MWApi) FindDuplicatesSHA1(hash string) ([]Duplicate, error) {
+	return m.FindDuplicatesSHA1Context(context.Background(), hash)
+}
+
+// FindDuplicatesSHA1Context is like FindDuplicatesSHA1 but uses ctx for
+// the queries.
+func (m *MWApi) FindDuplicatesSHA1Context(ctx context.Context, hash string) ([]Duplicate, error) {
+	duplicates, err := m.findDuplicates(ctx, map[string]string{
+		"list":    "allimages",
+		"aisha1":  hash,
+		"aiprop":  "timestamp",
+		"ailimit": "max",
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	deleted, err := m.findDuplicates(ctx, map[string]string{
+		"list":    "filearchive",
+		"fasha1":  hash,
+		"faprop":  "timestamp",
+		"falimit": "max",
+	})
+	// Most users can't see deleted files.
+	if errors.Is(err, ErrPermissionDenied) {
+		return duplicates, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return append(duplicates, deleted...), nil
+}
+
+// Run a list=allimages or list=filearchive query and collect the files.
+func (m *MWApi) findDuplicates(ctx context.Context, query map[string]string) ([]Duplicate, error) {
+	var duplicates []Duplicate
+	iter := m.QueryContext(ctx, query)
+	for iter.Next() {
+		var response duplicatesResponse
+		err := json.Unmarshal(iter.Body(), &response)
+		if err != nil {
+			return nil, err
+		}
+		duplicates = append(duplicates, response.Query.AllImages...)
+		for _, file := range response.Query.FileArchive {
+			file.Deleted = true
+			duplicates = append(duplicates, file)
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return nil, err
+	}
+	return duplicates, nil
+}
+
+// Look for an existing copy of request.File and handle it according to
+// request.OnDuplicate. It returns nil if the file should be uploaded.
+func (m *MWApi) checkDuplicates(ctx context.Context, request *UploadRequest) (*UploadResult, error) {
+	seeker, ok := request.File.(io.Seeker)
+	if !ok {
+		return nil, errors.New("checking for duplicates needs a file that is an io.Seeker")
+	}
+	start, err := seeker.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return nil, err
+	}
+	duplicates, err := m.FindDuplicatesContext(ctx, request.File)
+	if err != nil {
+		return nil, err
+	}
+	_, err = seeker.Seek(start, io.SeekStart)
+	if err != nil {
+		return nil, err
+	}
+
+	// Deleted files can't be used in place of the upload.
+	var existing string
+	for _, duplicate := range duplicates {
+		if !duplicate.Deleted {
+			existing = duplicate.Name
+			break
+		}
+	}
+	if existing == "" {
+		return nil, nil
+	}
+
+	result := &UploadResult{Result: "Duplicate", Filename: existing, Duplicates: duplicates}
+	if request.OnDuplicate == DuplicateRedirect && existing != strings.Replace(request.Filename, " ", "_", -1) {
+		_, err := m.EditPageContext(ctx, &EditRequest{
+			Title:      "File:" + request.Filename,
+			Text:       "#REDIRECT [[File:" + existing + "]]",
+			Summary:    request.Comment,
+			Tags:       request.Tags,
+			CreateOnly: true,
+			Watchlist:  request.Watchlist,
+		})
+		if err != nil {
+			return nil, err
+		}
+		result.Result = "Redirect"
+	}
+	return result, nil
+}
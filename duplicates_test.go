@@ -0,0 +1,147 @@
+package mediawiki
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const permissiondenied = `{"error":{"code":"permissiondenied","info":"You don't have permission to view deleted file information."}}`
+
+// A wiki that has a copy of the file "stuff" as Stuff.txt, and a deleted
+// copy if deleted is set.
+func duplicateServer(t *testing.T, deleted bool, edits *[]string) *httptest.Server {
+	hash := sha1.Sum([]byte("stuff"))
+	sum := hex.EncodeToString(hash[:])
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		err := r.ParseForm()
+		if err != nil {
+			panic(err)
+		}
+		switch {
+		case r.Form.Get("meta") == "tokens":
+			fmt.Fprintln(w, editTokenReponse)
+		case r.Form.Get("list") == "allimages":
+			if r.Form.Get("aisha1") != sum {
+				t.Errorf("Wrong hash: %s", r.Form.Get("aisha1"))
+			}
+			fmt.Fprintln(w, `{"batchcomplete":"","query":{"allimages":[{"name":"Stuff.txt","title":"File:Stuff.txt","timestamp":"2016-05-01T10:00:00Z"}]}}`)
+		case r.Form.Get("list") == "filearchive":
+			if !deleted {
+				fmt.Fprintln(w, permissiondenied)
+				return
+			}
+			fmt.Fprintln(w, `{"batchcomplete":"","query":{"filearchive":[{"id":1,"name":"Old_stuff.txt","title":"File:Old stuff.txt","timestamp":"2015-05-01T10:00:00Z"}]}}`)
+		case r.Form.Get("action") == "edit":
+			*edits = append(*edits, r.Form.Get("title")+" "+r.Form.Get("text"))
+			fmt.Fprintln(w, editsuccess)
+		default:
+			t.Errorf("Unexpected request: %v", r.Form)
+		}
+	}))
+}
+
+func TestFindDuplicates(t *testing.T) {
+	ts := duplicateServer(t, true, nil)
+	defer ts.Close()
+	client, err := New(ts.URL, "TESTING")
+	if err != nil {
+		t.Fatalf("Error creating client: %s", err)
+	}
+
+	duplicates, err := client.FindDuplicates(strings.NewReader("stuff"))
+	if err != nil {
+		t.Fatalf("Error finding duplicates: %s", err)
+	}
+	if len(duplicates) != 2 {
+		t.Fatalf("Expected 2 duplicates, got %v", duplicates)
+	}
+	if duplicates[0].Name != "Stuff.txt" || duplicates[0].Deleted {
+		t.Errorf("Wrong duplicate: %+v", duplicates[0])
+	}
+	if duplicates[1].Name != "Old_stuff.txt" || !duplicates[1].Deleted {
+		t.Errorf("Wrong deleted duplicate: %+v", duplicates[1])
+	}
+}
+
+func TestFindDuplicatesNoDeletedHistory(t *testing.T) {
+	ts := duplicateServer(t, false, nil)
+	defer ts.Close()
+	client, err := New(ts.URL, "TESTING")
+	if err != nil {
+		t.Fatalf("Error creating client: %s", err)
+	}
+
+	duplicates, err := client.FindDuplicates(strings.NewReader("stuff"))
+	if err != nil {
+		t.Fatalf("Error finding duplicates: %s", err)
+	}
+	if len(duplicates) != 1 || duplicates[0].Name != "Stuff.txt" {
+		t.Errorf("Wrong duplicates: %v", duplicates)
+	}
+}
+
+func TestUploadDuplicateRedirect(t *testing.T) {
+	var edits []string
+	ts := duplicateServer(t, false, &edits)
+	defer ts.Close()
+	client, err := New(ts.URL, "TESTING")
+	if err != nil {
+		t.Fatalf("Error creating client: %s", err)
+	}
+
+	file := strings.NewReader("stuff")
+	result, err := client.UploadFile(&UploadRequest{
+		Filename:    "New stuff.txt",
+		File:        file,
+		OnDuplicate: DuplicateRedirect,
+	})
+	if err != nil {
+		t.Fatalf("Upload failed: %s", err)
+	}
+	if result.Result != "Redirect" || result.Filename != "Stuff.txt" {
+		t.Errorf("Wrong result: %+v", result)
+	}
+	if len(edits) != 1 || edits[0] != "File:New stuff.txt #REDIRECT [[File:Stuff.txt]]" {
+		t.Errorf("Wrong redirect made: %v", edits)
+	}
+	if file.Len() != 5 {
+		t.Errorf("File was not rewound after hashing")
+	}
+}
+
+func TestUploadDuplicateSkip(t *testing.T) {
+	var edits []string
+	ts := duplicateServer(t, false, &edits)
+	defer ts.Close()
+	client, err := New(ts.URL, "TESTING")
+	if err != nil {
+		t.Fatalf("Error creating client: %s", err)
+	}
+
+	result, err := client.UploadFile(&UploadRequest{
+		Filename:    "New stuff.txt",
+		File:        strings.NewReader("stuff"),
+		OnDuplicate: DuplicateSkip,
+	})
+	if err != nil {
+		t.Fatalf("Upload failed: %s", err)
+	}
+	if result.Result != "Duplicate" || result.Filename != "Stuff.txt" || len(edits) != 0 {
+		t.Errorf("Wrong result: %+v", result)
+	}
+
+	_, err = client.UploadFile(&UploadRequest{
+		Filename:    "New stuff.txt",
+		File:        struct{ io.Reader }{strings.NewReader("stuff")},
+		OnDuplicate: DuplicateSkip,
+	})
+	if err == nil {
+		t.Errorf("Duplicate check didn't fail for a file that can't seek")
+	}
+}
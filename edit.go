@@ -0,0 +1,331 @@
+//
+// Copyright 2016 James McGuire
+//
+// This code is covered under the MIT License
+// Please refer to the LICENSE file in the root of this
+// repository for any information.
+
+package mediawiki
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Values for EditRequest.Watchlist.
+const (
+	WatchlistWatch       = "watch"
+	WatchlistUnwatch     = "unwatch"
+	WatchlistPreferences = "preferences"
+	WatchlistNoChange    = "nochange"
+)
+
+// EditRequest describes an edit made with EditPage. Either Title or PageID
+// must be set.
+//
+// Text replaces the page (or Section) unless AppendText or PrependText are
+// set, so an EditRequest with none of them blanks the page.
+//
+// See https://www.mediawiki.org/wiki/API:Edit
+type EditRequest struct {
+	Title  string
+	PageID int
+
+	Text        string
+	AppendText  string
+	PrependText string
+	// Section is the section number to edit, or "new" to add a section
+	// titled SectionTitle. Empty means the whole page.
+	Section      string
+	SectionTitle string
+
+	Summary string
+	Minor   bool
+	Bot     bool
+	Tags    []string
+
+	// BaseTimestamp is the timestamp of the revision the edit is based on
+	// and StartTimestamp is when the page was read. If the page has been
+	// changed or deleted since, the edit fails with ErrEditConflict or
+	// ErrPageDeleted instead of overwriting the other change.
+	BaseTimestamp  time.Time
+	StartTimestamp time.Time
+
+	// CreateOnly fails with ErrArticleExists if the page exists and
+	// NoCreate fails with ErrMissingTitle if it doesn't.
+	CreateOnly bool
+	NoCreate   bool
+
+	// Watchlist is one of the Watchlist* values, empty uses the default.
+	Watchlist string
+}
+
+// EditResult is the result of an edit.
+type EditResult struct {
+	Result       string
+	PageID       int
+	Title        string
+	ContentModel string
+	OldRevID     int
+	NewRevID     int
+	NewTimestamp time.Time
+	// NoChange is set when the edit didn't change the page, in which case
+	// no new revision is created.
+	NoChange bool
+	// New is set when the edit created the page.
+	New bool
+	// Captcha holds the details of the CAPTCHA that must be solved when
+	// the edit failed because of one.
+	Captcha map[string]interface{}
+	// Warnings returned with the edit.
+	Warnings Warnings
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (r *EditResult) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		Result       string
+		PageID       int
+		Title        string
+		ContentModel string
+		OldRevID     int
+		NewRevID     int
+		NewTimestamp time.Time
+		NoChange     json.RawMessage
+		New          json.RawMessage
+		Captcha      map[string]interface{}
+	}
+	err := json.Unmarshal(data, &raw)
+	if err != nil {
+		return err
+	}
+	*r = EditResult{
+		Result:       raw.Result,
+		PageID:       raw.PageID,
+		Title:        raw.Title,
+		ContentModel: raw.ContentModel,
+		OldRevID:     raw.OldRevID,
+		NewRevID:     raw.NewRevID,
+		NewTimestamp: raw.NewTimestamp,
+		NoChange:     jsonBool(raw.NoChange),
+		New:          jsonBool(raw.New),
+		Captcha:      raw.Captcha,
+	}
+	return nil
+}
+
+// Unmarshall response from page edits...
+type outerEdit struct {
+	Edit EditResult
+}
+
+// Format a timestamp the way the API expects it.
+func formatTimestamp(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
+
+// values converts the request in to API parameters.
+func (r *EditRequest) values() (map[string]string, error) {
+	values := map[string]string{}
+	switch {
+	case r.Title != "":
+		values["title"] = r.Title
+	case r.PageID != 0:
+		values["pageid"] = strconv.Itoa(r.PageID)
+	default:
+		return nil, errors.New("no title or pageid supplied")
+	}
+
+	if r.AppendText == "" && r.PrependText == "" {
+		values["text"] = r.Text
+	}
+	if r.AppendText != "" {
+		values["appendtext"] = r.AppendText
+	}
+	if r.PrependText != "" {
+		values["prependtext"] = r.PrependText
+	}
+	if r.Section != "" {
+		values["section"] = r.Section
+	}
+	if r.SectionTitle != "" {
+		values["sectiontitle"] = r.SectionTitle
+	}
+	if r.Summary != "" {
+		values["summary"] = r.Summary
+	}
+	if r.Minor {
+		values["minor"] = "1"
+	}
+	if r.Bot {
+		values["bot"] = "1"
+	}
+	if len(r.Tags) > 0 {
+		values["tags"] = strings.Join(r.Tags, "|")
+	}
+	if !r.BaseTimestamp.IsZero() {
+		values["basetimestamp"] = formatTimestamp(r.BaseTimestamp)
+	}
+	if !r.StartTimestamp.IsZero() {
+		values["starttimestamp"] = formatTimestamp(r.StartTimestamp)
+	}
+	if r.CreateOnly {
+		values["createonly"] = "1"
+	}
+	if r.NoCreate {
+		values["nocreate"] = "1"
+	}
+	if r.Watchlist != "" {
+		values["watchlist"] = r.Watchlist
+	}
+	return values, nil
+}
+
+// EditPage edits a page and returns the result.
+//
+// Like Edit, an edit token is requested automatically. If the page was
+// changed after BaseTimestamp the error satisfies
+// errors.Is(err, ErrEditConflict). When the server returns a result other
+// than Success, such as a CAPTCHA request, both the result and an
+// *APIError are returned.
+//
+// Example:
+//
+//	result, err := client.EditPage(&mediawiki.EditRequest{
+//	    Title:   "SOME PAGE",
+//	    Summary: "THIS IS WHAT SHOWS UP IN THE LOG",
+//	    Text:    "THE ENTIRE TEXT OF THE PAGE",
+//	})
+func (m *MWApi) EditPage(request *EditRequest) (*EditResult, error) {
+	return m.EditPageContext(context.Background(), request)
+}
+
+// EditPageContext is like EditPage but uses ctx for the token lookup and
+// the edit.
+func (m *MWApi) EditPageContext(ctx context.Context, request *EditRequest) (*EditResult, error) {
+	values, err := request.values()
+	if err != nil {
+		return nil, err
+	}
+	return m.edit(ctx, values)
+}
+
+// Make an edit with the given parameters.
+func (m *MWApi) edit(ctx context.Context, values map[string]string) (*EditResult, error) {
+	body, err := m.withToken(ctx, CSRFToken, func(token string) ([]byte, error) {
+		query := map[string]string{
+			"action": "edit",
+			"token":  token,
+		}
+		return m.APIContext(ctx, query, values)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var response outerEdit
+	err = json.Unmarshal(body, &response)
+	if err != nil {
+		return nil, err
+	}
+	result := &response.Edit
+	result.Warnings = parseWarnings(body)
+
+	if result.Result != "Success" {
+		return result, &APIError{Code: result.Result, Module: "edit"}
+	}
+	return result, nil
+}
+
+// TransformFunc computes the new text of a page from its current text,
+// which is empty if the page doesn't exist yet. It also returns the edit
+// summary to use.
+type TransformFunc func(old string) (text, summary string, err error)
+
+// Transform reads the current text of a page, passes it to fn and saves the
+// text fn returns, making sure no other edit is overwritten in between.
+//
+// The edit is sent with the timestamps of the revision that was read, so if
+// somebody else changes the page first the edit conflicts instead of
+// clobbering their change. On a conflict the page is read again and fn is
+// applied to the new text, up to TransformAttempts times. If the page is
+// deleted in between, the error from the edit is returned instead.
+//
+// If fn returns the text unchanged no edit is made and the result has
+// NoChange set.
+func (m *MWApi) Transform(title string, fn TransformFunc) (*EditResult, error) {
+	return m.TransformContext(context.Background(), title, fn)
+}
+
+// TransformContext is like Transform but uses ctx for every request.
+func (m *MWApi) TransformContext(ctx context.Context, title string, fn TransformFunc) (*EditResult, error) {
+	attempts := m.TransformAttempts
+	if attempts <= 0 {
+		attempts = 3
+	}
+
+	var err error
+	for attempt := 0; attempt < attempts; attempt++ {
+		var result *EditResult
+		result, err = m.transform(ctx, title, fn)
+		// Only retry when somebody else saved first. A page deleted in the
+		// meantime is not recreated.
+		if !errors.Is(err, ErrEditConflict) && !errors.Is(err, ErrArticleExists) {
+			return result, err
+		}
+	}
+	return nil, err
+}
+
+// Make a single read-modify-write attempt.
+func (m *MWApi) transform(ctx context.Context, title string, fn TransformFunc) (*EditResult, error) {
+	query := map[string]string{
+		"action":       "query",
+		"prop":         "revisions",
+		"titles":       title,
+		"rvprop":       "content|timestamp",
+		"curtimestamp": "1",
+	}
+	body, err := m.APIContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	var response Response
+	err = json.Unmarshal(body, &response)
+	if err != nil {
+		return nil, err
+	}
+	pl := response.PageSlice()
+	if len(pl) != 1 {
+		return nil, errors.New("received unexpected number of pages")
+	}
+	page := pl[0]
+
+	request := &EditRequest{
+		Title:          page.Title,
+		StartTimestamp: response.CurTimestamp,
+	}
+	var old string
+	if len(page.Revisions) > 0 {
+		old = page.Revisions[0].Body
+		request.BaseTimestamp = page.Revisions[0].Timestamp
+		request.NoCreate = true
+	} else {
+		// Don't overwrite a page somebody else creates in the meantime.
+		request.CreateOnly = true
+	}
+
+	request.Text, request.Summary, err = fn(old)
+	if err != nil {
+		return nil, err
+	}
+	if request.Text == old {
+		return &EditResult{Result: "Success", PageID: page.Pageid, Title: page.Title, NoChange: true}, nil
+	}
+	return m.EditPageContext(ctx, request)
+}
@@ -0,0 +1,272 @@
+package mediawiki
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+const (
+	editResult   = `{"warnings":{"edit":{"*":"Unrecognized parameter: foo."}},"edit":{"result":"Success","pageid":12,"title":"Talk:Main Page","contentmodel":"wikitext","oldrevid":465,"newrevid":471,"newtimestamp":"2016-05-04T12:00:00Z"}}`
+	editNoChange = `{"edit":{"result":"Success","pageid":12,"title":"Talk:Main Page","contentmodel":"wikitext","nochange":""}}`
+	editCaptcha  = `{"edit":{"captcha":{"type":"image","mime":"image/png","id":"1234","url":"/w/index.php?title=Special:Captcha/image&wpCaptchaId=1234"},"result":"Failure"}}`
+)
+
+func TestEditPage(t *testing.T) {
+	base := time.Date(2016, 5, 4, 10, 0, 0, 0, time.UTC)
+	start := time.Date(2016, 5, 4, 11, 0, 0, 0, time.FixedZone("PDT", -7*60*60))
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		err := r.ParseForm()
+		if err != nil {
+			panic(err)
+		}
+		expected := map[string]string{
+			"action":         "edit",
+			"token":          "asdf",
+			"title":          "Talk:Main Page",
+			"appendtext":     "MORE TEXT",
+			"section":        "new",
+			"sectiontitle":   "Hello",
+			"summary":        "SUMMARY",
+			"minor":          "1",
+			"bot":            "1",
+			"tags":           "foo|bar",
+			"basetimestamp":  "2016-05-04T10:00:00Z",
+			"starttimestamp": "2016-05-04T18:00:00Z",
+			"nocreate":       "1",
+			"watchlist":      "nochange",
+		}
+		for key, value := range expected {
+			if r.Form.Get(key) != value {
+				t.Errorf("Expected %s=%q, got %q", key, value, r.Form.Get(key))
+			}
+		}
+		for _, key := range []string{"text", "prependtext", "createonly", "pageid"} {
+			if _, ok := r.Form[key]; ok {
+				t.Errorf("Unexpected parameter %s", key)
+			}
+		}
+		fmt.Fprintln(w, editResult)
+	}))
+	defer ts.Close()
+	client, err := New(ts.URL, "TESTING")
+	if err != nil {
+		t.Fatalf("Error creating client: %s", err)
+	}
+	client.tokens[CSRFToken] = "asdf"
+
+	result, err := client.EditPage(&EditRequest{
+		Title:          "Talk:Main Page",
+		AppendText:     "MORE TEXT",
+		Section:        "new",
+		SectionTitle:   "Hello",
+		Summary:        "SUMMARY",
+		Minor:          true,
+		Bot:            true,
+		Tags:           []string{"foo", "bar"},
+		BaseTimestamp:  base,
+		StartTimestamp: start,
+		NoCreate:       true,
+		Watchlist:      WatchlistNoChange,
+	})
+	if err != nil {
+		t.Fatalf("Error editing page: %s", err)
+	}
+	if result.PageID != 12 || result.OldRevID != 465 || result.NewRevID != 471 || result.NoChange {
+		t.Errorf("Wrong edit result: %#v", result)
+	}
+	if !result.NewTimestamp.Equal(time.Date(2016, 5, 4, 12, 0, 0, 0, time.UTC)) {
+		t.Errorf("Wrong new timestamp: %s", result.NewTimestamp)
+	}
+	if len(result.Warnings) != 1 || result.Warnings[0].Module != "edit" {
+		t.Errorf("Warnings not included in result: %v", result.Warnings)
+	}
+}
+
+func TestEditPageResults(t *testing.T) {
+	test := BuildUp(editNoChange, t)
+	defer test.TearDown()
+	test.client.tokens[CSRFToken] = "asdf"
+	result, err := test.client.EditPage(&EditRequest{PageID: 12, Text: "SAME TEXT"})
+	if err != nil {
+		t.Fatalf("Error editing page: %s", err)
+	}
+	if !result.NoChange || result.New {
+		t.Errorf("nochange was not parsed: %#v", result)
+	}
+
+	test = BuildUp(editCaptcha, t)
+	defer test.TearDown()
+	test.client.tokens[CSRFToken] = "asdf"
+	result, err = test.client.EditPage(&EditRequest{Title: "Foo", Text: "http://spam.example.org"})
+	if err == nil {
+		t.Fatal("Failed edit did not return an error")
+	}
+	if result == nil || result.Captcha["id"] != "1234" {
+		t.Errorf("Captcha details were not returned: %#v", result)
+	}
+
+	_, err = test.client.EditPage(&EditRequest{Text: "NO TITLE"})
+	if err == nil {
+		t.Error("Edit without a title or pageid did not return an error")
+	}
+}
+
+func TestEditPageConflict(t *testing.T) {
+	test := BuildUp(editconflict, t)
+	defer test.TearDown()
+	test.client.tokens[CSRFToken] = "asdf"
+	_, err := test.client.EditPage(&EditRequest{
+		Title:         "Foo",
+		Text:          "NEW TEXT",
+		BaseTimestamp: time.Now(),
+	})
+	if !errors.Is(err, ErrEditConflict) {
+		t.Fatalf("Expected an edit conflict, got: %v", err)
+	}
+}
+
+// A wiki with a single page that somebody else edits once while the
+// client is working on it.
+type transformServer struct {
+	t         *testing.T
+	text      string
+	timestamp string
+	exists    bool
+	// Set to make the next edit conflict, as if another user saved first.
+	interfere bool
+	// Set to delete the page before the next edit.
+	remove bool
+	reads  int
+	edits  int
+}
+
+func (s *transformServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		panic(err)
+	}
+	if r.Form.Get("meta") == "tokens" {
+		fmt.Fprintln(w, editTokenReponse)
+		return
+	}
+	switch r.Form.Get("action") {
+	case "query":
+		s.reads++
+		if r.Form.Get("curtimestamp") == "" {
+			s.t.Errorf("curtimestamp was not requested: %v", r.Form)
+		}
+		if !s.exists {
+			fmt.Fprintln(w, `{"curtimestamp":"2016-05-04T12:00:00Z","query":{"pages":{"-1":{"ns":0,"title":"Foo","missing":""}}}}`)
+			return
+		}
+		fmt.Fprintf(w, `{"curtimestamp":"2016-05-04T12:00:00Z","query":{"pages":{"7":{"pageid":7,"ns":0,"title":"Foo","revisions":[{"timestamp":%q,"*":%q}]}}}}`, s.timestamp, s.text)
+	case "edit":
+		s.edits++
+		if r.Form.Get("starttimestamp") != "2016-05-04T12:00:00Z" {
+			s.t.Errorf("Wrong starttimestamp: %v", r.Form)
+		}
+		if !s.exists {
+			if r.Form.Get("createonly") != "1" {
+				s.t.Errorf("New page was not created with createonly: %v", r.Form)
+			}
+		} else if r.Form.Get("basetimestamp") != s.timestamp {
+			s.t.Errorf("Wrong basetimestamp: %v", r.Form)
+		}
+		if s.remove {
+			s.remove = false
+			s.exists = false
+			fmt.Fprintln(w, `{"error":{"code":"missingtitle","info":"The page you specified doesn't exist."}}`)
+			return
+		}
+		if s.interfere {
+			s.interfere = false
+			s.exists = true
+			s.text += " OTHER"
+			s.timestamp = "2016-05-04T11:30:00Z"
+			fmt.Fprintln(w, editconflict)
+			return
+		}
+		s.exists = true
+		s.text = r.Form.Get("text")
+		fmt.Fprintln(w, editsuccess)
+	default:
+		s.t.Errorf("Unexpected request: %v", r.Form)
+	}
+}
+
+func TestTransformConflict(t *testing.T) {
+	server := &transformServer{t: t, text: "START", timestamp: "2016-05-04T11:00:00Z", exists: true, interfere: true}
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+	client, err := New(ts.URL, "TESTING")
+	if err != nil {
+		t.Fatalf("Error creating client: %s", err)
+	}
+
+	calls := 0
+	_, err = client.Transform("Foo", func(old string) (string, string, error) {
+		calls++
+		return old + " MINE", "appending", nil
+	})
+	if err != nil {
+		t.Fatalf("Transform failed: %s", err)
+	}
+	if server.text != "START OTHER MINE" {
+		t.Errorf("Concurrent edit was clobbered: %q", server.text)
+	}
+	if calls != 2 || server.reads != 2 || server.edits != 2 {
+		t.Errorf("Expected 2 calls, reads and edits, got %d, %d and %d", calls, server.reads, server.edits)
+	}
+}
+
+func TestTransformCreate(t *testing.T) {
+	server := &transformServer{t: t, interfere: true}
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+	client, err := New(ts.URL, "TESTING")
+	if err != nil {
+		t.Fatalf("Error creating client: %s", err)
+	}
+	client.TransformAttempts = 1
+
+	_, err = client.Transform("Foo", func(old string) (string, string, error) {
+		return old + "NEW PAGE", "creating", nil
+	})
+	if !errors.Is(err, ErrEditConflict) {
+		t.Fatalf("Expected a conflict once attempts ran out, got: %v", err)
+	}
+
+	result, err := client.Transform("Foo", func(old string) (string, string, error) {
+		return old, "nothing", nil
+	})
+	if err != nil {
+		t.Fatalf("Transform failed: %s", err)
+	}
+	if !result.NoChange || server.edits != 1 {
+		t.Errorf("An unchanged page was saved: %#v", result)
+	}
+}
+
+func TestTransformDeleted(t *testing.T) {
+	server := &transformServer{t: t, text: "START", timestamp: "2016-05-04T11:00:00Z", exists: true, remove: true}
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+	client, err := New(ts.URL, "TESTING")
+	if err != nil {
+		t.Fatalf("Error creating client: %s", err)
+	}
+
+	_, err = client.Transform("Foo", func(old string) (string, string, error) {
+		return old + " MINE", "appending", nil
+	})
+	if !errors.Is(err, ErrMissingTitle) {
+		t.Fatalf("Expected missingtitle for a deleted page, got: %v", err)
+	}
+	if server.exists || server.edits != 1 {
+		t.Errorf("Deleted page was created again after %d edits", server.edits)
+	}
+}
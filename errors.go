@@ -0,0 +1,117 @@
+//
+// Copyright 2016 James McGuire
+//
+// This code is covered under the MIT License
+// Please refer to the LICENSE file in the root of this
+// repository for any information.
+
+package mediawiki
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// APIError is returned whenever the MediaWiki API reports a failure, either
+// through an error object in the response or through an unsuccessful result
+// from a module such as edit, upload or login.
+//
+// Errors can be compared against the Err* values with errors.Is, which
+// matches on Code alone:
+//
+//	err = client.Edit(editConfig)
+//	if errors.Is(err, mediawiki.ErrEditConflict) {
+//	    // Reload the page and try again
+//	}
+type APIError struct {
+	// Code is the MediaWiki error code, or the result value for modules
+	// that report failure through their result.
+	Code string
+	// Info is the human readable description of the error.
+	Info string
+	// DocRef points to the API documentation, when the server includes it.
+	DocRef string
+	// HTTPStatus is the status code of the HTTP response.
+	HTTPStatus int
+	// Module is the API module (the action parameter) that failed.
+	Module string
+}
+
+func (e *APIError) Error() string {
+	if e.Info == "" {
+		return e.Code
+	}
+	return e.Code + ": " + e.Info
+}
+
+// Is reports whether target is an *APIError with the same Code.
+func (e *APIError) Is(target error) bool {
+	t, ok := target.(*APIError)
+	return ok && t.Code == e.Code
+}
+
+// Common error codes. See https://www.mediawiki.org/wiki/API:Errors_and_warnings
+var (
+	ErrBadToken         = &APIError{Code: "badtoken"}
+	ErrEditConflict     = &APIError{Code: "editconflict"}
+	ErrProtectedPage    = &APIError{Code: "protectedpage"}
+	ErrRateLimited      = &APIError{Code: "ratelimited"}
+	ErrMaxLag           = &APIError{Code: "maxlag"}
+	ErrReadOnly         = &APIError{Code: "readonly"}
+	ErrMissingTitle     = &APIError{Code: "missingtitle"}
+	ErrInvalidTitle     = &APIError{Code: "invalidtitle"}
+	ErrNoSuchRevID      = &APIError{Code: "nosuchrevid"}
+	ErrArticleExists    = &APIError{Code: "articleexists"}
+	ErrPageDeleted      = &APIError{Code: "pagedeleted"}
+	ErrPermissionDenied = &APIError{Code: "permissiondenied"}
+	ErrAssertUserFailed = &APIError{Code: "assertuserfailed"}
+	ErrAssertBotFailed  = &APIError{Code: "assertbotfailed"}
+	ErrBlocked          = &APIError{Code: "blocked"}
+	// ErrUploadWarning is returned by UploadFile when the server refused
+	// an upload because of warnings, see UploadResult.UploadWarnings.
+	ErrUploadWarning = &APIError{Code: "Warning"}
+	// ErrHTTP is used when the server responds with a non-2xx status code
+	// but without a MediaWiki error.
+	ErrHTTP = &APIError{Code: "http"}
+)
+
+type mwError struct {
+	Error struct {
+		Code   string
+		Info   string
+		DocRef string
+		// Older versions only return the documentation reference as '*'
+		Star string `json:"*"`
+	}
+}
+
+// Helper function for translating MediaWiki errors in to Golang errors.
+//
+// Returns an *APIError if the response contains an error object or if the
+// status code is not 2xx, otherwise nil.
+func checkError(response []byte, status int, module string) error {
+	var mwerror mwError
+	err := json.Unmarshal(response, &mwerror)
+	if err == nil && mwerror.Error.Code != "" {
+		docref := mwerror.Error.DocRef
+		if docref == "" {
+			docref = mwerror.Error.Star
+		}
+		return &APIError{
+			Code:       mwerror.Error.Code,
+			Info:       mwerror.Error.Info,
+			DocRef:     docref,
+			HTTPStatus: status,
+			Module:     module,
+		}
+	}
+	if status != 0 && (status < 200 || status > 299) {
+		return &APIError{
+			Code:       ErrHTTP.Code,
+			Info:       http.StatusText(status),
+			HTTPStatus: status,
+			Module:     module,
+		}
+	}
+	return nil
+}
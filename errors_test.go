@@ -0,0 +1,105 @@
+package mediawiki
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const editconflict = `{"error":{"code":"editconflict","info":"Edit conflict.","docref":"See https://example.org/w/api.php for API usage."}}`
+
+func TestAPIErrorFields(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("MediaWiki-API-Error", "editconflict")
+		fmt.Fprintln(w, editconflict)
+	}))
+	defer ts.Close()
+	client, err := New(ts.URL, "TESTING")
+	if err != nil {
+		t.Fatalf("Error creating client: %s", err)
+	}
+	client.tokens[CSRFToken] = "asdf"
+
+	err = client.Edit(map[string]string{"title": "somepage"})
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("Expected an *APIError, got %T: %v", err, err)
+	}
+	if apiErr.Code != "editconflict" || apiErr.Info != "Edit conflict." {
+		t.Errorf("Wrong code or info: %#v", apiErr)
+	}
+	if apiErr.DocRef != "See https://example.org/w/api.php for API usage." {
+		t.Errorf("Wrong docref: %s", apiErr.DocRef)
+	}
+	if apiErr.HTTPStatus != http.StatusOK {
+		t.Errorf("Wrong HTTP status: %d", apiErr.HTTPStatus)
+	}
+	if apiErr.Module != "edit" {
+		t.Errorf("Wrong module: %s", apiErr.Module)
+	}
+	if !errors.Is(err, ErrEditConflict) {
+		t.Error("errors.Is did not match ErrEditConflict")
+	}
+	if errors.Is(err, ErrProtectedPage) {
+		t.Error("errors.Is matched the wrong sentinel")
+	}
+}
+
+func TestAPIErrorLegacyDocRef(t *testing.T) {
+	test := BuildUp(`{"error":{"code":"readonly","info":"The wiki is in read-only mode.","*":"See api.php"}}`, t)
+	defer test.TearDown()
+	_, err := test.client.API(map[string]string{"action": "query"})
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("Expected an *APIError, got %T: %v", err, err)
+	}
+	if apiErr.DocRef != "See api.php" || apiErr.Module != "query" {
+		t.Errorf("Wrong error fields: %#v", apiErr)
+	}
+	if !errors.Is(err, ErrReadOnly) {
+		t.Error("errors.Is did not match ErrReadOnly")
+	}
+}
+
+func TestAPIErrorHTTPStatus(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "<html>Bad Gateway</html>", http.StatusBadGateway)
+	}))
+	defer ts.Close()
+	client, err := New(ts.URL, "TESTING")
+	if err != nil {
+		t.Fatalf("Error creating client: %s", err)
+	}
+	_, err = client.API(map[string]string{"action": "query"})
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("Expected an *APIError, got %T: %v", err, err)
+	}
+	if apiErr.HTTPStatus != http.StatusBadGateway || !errors.Is(err, ErrHTTP) {
+		t.Errorf("Wrong error for non-2xx response: %#v", apiErr)
+	}
+}
+
+func TestAPIErrorResults(t *testing.T) {
+	test := BuildUp(`{"login":{"result":"Failed","reason":"Incorrect password entered."}}`, t)
+	defer test.TearDown()
+	err := test.client.Login("asdf", "asdf")
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("Expected an *APIError from Login, got %T: %v", err, err)
+	}
+	if apiErr.Code != "Failed" || apiErr.Info != "Incorrect password entered." || apiErr.Module != "login" {
+		t.Errorf("Wrong login error: %#v", apiErr)
+	}
+
+	test = BuildUp(`{"upload":{"result":"Failure"}}`, t)
+	defer test.TearDown()
+	test.client.tokens[CSRFToken] = "asdf"
+	err = test.client.Upload("stuff", strings.NewReader("stuff"))
+	if !errors.As(err, &apiErr) || apiErr.Module != "upload" || apiErr.Code != "Failure" {
+		t.Errorf("Wrong upload error: %v", err)
+	}
+}
@@ -0,0 +1,132 @@
+//
+// Copyright 2016 James McGuire
+//
+// This code is covered under the MIT License
+// Please refer to the LICENSE file in the root of this
+// repository for any information.
+
+package mediawiki
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+)
+
+// ExtMetadataValue is a single property from iiprop=extmetadata, for
+// example Artist or LicenseShortName.
+//
+// See https://www.mediawiki.org/wiki/API:Imageinfo#Extmetadata
+type ExtMetadataValue struct {
+	// Value is usually a string, which may contain HTML.
+	Value  interface{} `json:"value"`
+	Source string      `json:"source"`
+}
+
+// MetadataItem is a single entry of the raw file metadata from
+// iiprop=metadata, such as an EXIF tag. Value may itself be a list of
+// MetadataItems, decoded as []interface{}.
+type MetadataItem struct {
+	Name  string      `json:"name"`
+	Value interface{} `json:"value"`
+}
+
+// FileInfo returns every version of a file, newest first, with their
+// metadata. If the file doesn't exist the error satisfies
+// errors.Is(err, ErrMissingTitle).
+func (m *MWApi) FileInfo(filename string) ([]ImageInfo, error) {
+	return m.FileInfoContext(context.Background(), filename)
+}
+
+// FileInfoContext is like FileInfo but uses ctx for every request.
+func (m *MWApi) FileInfoContext(ctx context.Context, filename string) ([]ImageInfo, error) {
+	query := map[string]string{
+		"prop":    "imageinfo",
+		"titles":  filename,
+		"iiprop":  "timestamp|user|comment|url|size|mime|mediatype|sha1|archivename|extmetadata|metadata",
+		"iilimit": "max",
+	}
+	pl, err := m.QueryContext(ctx, query).Pages()
+	if err != nil {
+		return nil, err
+	}
+	if len(pl) != 1 || len(pl[0].Imageinfo) < 1 {
+		return nil, missingFile(filename)
+	}
+	return pl[0].Imageinfo, nil
+}
+
+// Unmarshal filerevert responses...
+type fileRevertResponse struct {
+	FileRevert struct {
+		Result string
+	}
+}
+
+// RevertFile makes an old version of a file the current version again. The
+// version is identified by its ArchiveName from FileInfo, and filename may
+// be given with or without the File: prefix.
+func (m *MWApi) RevertFile(filename, archiveName, comment string) error {
+	return m.RevertFileContext(context.Background(), filename, archiveName, comment)
+}
+
+// RevertFileContext is like RevertFile but uses ctx for the token lookup
+// and the request.
+func (m *MWApi) RevertFileContext(ctx context.Context, filename, archiveName, comment string) error {
+	if archiveName == "" {
+		return errors.New("no archive name supplied")
+	}
+	body, err := m.withToken(ctx, CSRFToken, func(token string) ([]byte, error) {
+		query := map[string]string{
+			"action":      "filerevert",
+			"filename":    strings.TrimPrefix(filename, "File:"),
+			"archivename": archiveName,
+			"comment":     comment,
+			"token":       token,
+		}
+		return m.APIContext(ctx, query)
+	})
+	if err != nil {
+		return err
+	}
+
+	var response fileRevertResponse
+	err = json.Unmarshal(body, &response)
+	if err != nil {
+		return err
+	}
+	if response.FileRevert.Result != "Success" {
+		return &APIError{Code: response.FileRevert.Result, Module: "filerevert"}
+	}
+	return nil
+}
+
+// DeleteFileVersion deletes an old version of a file, identified by its
+// ArchiveName from FileInfo. The current version can't be deleted this
+// way. filename may be given with or without the File: prefix.
+func (m *MWApi) DeleteFileVersion(filename, archiveName, reason string) error {
+	return m.DeleteFileVersionContext(context.Background(), filename, archiveName, reason)
+}
+
+// DeleteFileVersionContext is like DeleteFileVersion but uses ctx for the
+// token lookup and the request.
+func (m *MWApi) DeleteFileVersionContext(ctx context.Context, filename, archiveName, reason string) error {
+	// Without oldimage the whole file would be deleted.
+	if archiveName == "" {
+		return errors.New("no archive name supplied")
+	}
+	_, err := m.withToken(ctx, CSRFToken, func(token string) ([]byte, error) {
+		query := map[string]string{
+			"action":   "delete",
+			"title":    "File:" + strings.TrimPrefix(filename, "File:"),
+			"oldimage": archiveName,
+			"token":    token,
+		}
+		if reason != "" {
+			query["reason"] = reason
+		}
+		return m.APIContext(ctx, query)
+	})
+	return err
+}
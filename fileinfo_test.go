@@ -0,0 +1,124 @@
+package mediawiki
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+const (
+	fileInfoFirst  = `{"continue":{"iistart":"2015-01-01T00:00:00Z","continue":"||"},"query":{"pages":{"107":{"pageid":107,"ns":6,"title":"File:Example.jpg","imageinfo":[{"timestamp":"2016-05-01T10:00:00Z","user":"Photographer","size":2048,"width":640,"height":480,"comment":"Cropped","url":"http://example.com/Example.jpg","mime":"image/jpeg","sha1":"aaaa","extmetadata":{"Artist":{"value":"Jane Doe","source":"commons-desc-page","hidden":""},"LicenseShortName":{"value":"CC BY-SA 4.0","source":"commons-desc-page"}},"metadata":[{"name":"Make","value":"Camera Co"},{"name":"ImageWidth","value":640}]}]}}}}`
+	fileInfoSecond = `{"batchcomplete":"","query":{"pages":{"107":{"pageid":107,"ns":6,"title":"File:Example.jpg","imageinfo":[{"timestamp":"2015-01-01T00:00:00Z","user":"Photographer","size":4096,"width":800,"height":600,"comment":"Original","url":"http://example.com/archive/Example.jpg","mime":"image/jpeg","sha1":"bbbb","archivename":"20160501100000!Example.jpg","extmetadata":{},"metadata":null}]}}}}`
+)
+
+func TestFileInfo(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		err := r.ParseForm()
+		if err != nil {
+			panic(err)
+		}
+		if r.Form.Get("iistart") == "" {
+			fmt.Fprintln(w, fileInfoFirst)
+		} else {
+			fmt.Fprintln(w, fileInfoSecond)
+		}
+	}))
+	defer ts.Close()
+	client, err := New(ts.URL, "TESTING")
+	if err != nil {
+		t.Fatalf("Error creating client: %s", err)
+	}
+
+	versions, err := client.FileInfo("File:Example.jpg")
+	if err != nil {
+		t.Fatalf("Error getting file info: %s", err)
+	}
+	if len(versions) != 2 {
+		t.Fatalf("Expected 2 versions, got %d", len(versions))
+	}
+	current, old := versions[0], versions[1]
+	if current.Width != 640 || current.Size != 2048 || current.Comment != "Cropped" || current.ArchiveName != "" {
+		t.Errorf("Wrong current version: %+v", current)
+	}
+	if current.ExtMetadata["Artist"].Value != "Jane Doe" || current.ExtMetadata["LicenseShortName"].Value != "CC BY-SA 4.0" {
+		t.Errorf("Wrong extmetadata: %v", current.ExtMetadata)
+	}
+	if len(current.Metadata) != 2 || current.Metadata[0].Name != "Make" || current.Metadata[1].Value != float64(640) {
+		t.Errorf("Wrong metadata: %v", current.Metadata)
+	}
+	if old.ArchiveName != "20160501100000!Example.jpg" || old.Sha1 != "bbbb" {
+		t.Errorf("Wrong old version: %+v", old)
+	}
+}
+
+func TestFileInfoMissing(t *testing.T) {
+	test := BuildUp(fileURLFailed, t)
+	defer test.TearDown()
+
+	_, err := test.client.FileInfo("File:Missing.jpg")
+	if !errors.Is(err, ErrMissingTitle) {
+		t.Fatalf("Expected a missingtitle error for a missing file, got %v", err)
+	}
+}
+
+func TestRevertFile(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		err := r.ParseForm()
+		if err != nil {
+			panic(err)
+		}
+		if r.Form.Get("meta") == "tokens" {
+			fmt.Fprintln(w, editTokenReponse)
+			return
+		}
+		if r.Form.Get("action") != "filerevert" || r.Form.Get("filename") != "Example.jpg" ||
+			r.Form.Get("archivename") != "20160501100000!Example.jpg" || r.Form.Get("token") != `+\` {
+			t.Errorf("Bad filerevert request: %v", r.Form)
+		}
+		fmt.Fprintln(w, `{"filerevert":{"result":"Success"}}`)
+	}))
+	defer ts.Close()
+	client, err := New(ts.URL, "TESTING")
+	if err != nil {
+		t.Fatalf("Error creating client: %s", err)
+	}
+
+	err = client.RevertFile("File:Example.jpg", "20160501100000!Example.jpg", "Undo crop")
+	if err != nil {
+		t.Fatalf("Error reverting file: %s", err)
+	}
+}
+
+func TestDeleteFileVersion(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		err := r.ParseForm()
+		if err != nil {
+			panic(err)
+		}
+		if r.Form.Get("meta") == "tokens" {
+			fmt.Fprintln(w, editTokenReponse)
+			return
+		}
+		if r.Form.Get("action") != "delete" || r.Form.Get("title") != "File:Example.jpg" ||
+			r.Form.Get("oldimage") != "20160501100000!Example.jpg" || r.Form.Get("reason") != "Private data" {
+			t.Errorf("Bad delete request: %v", r.Form)
+		}
+		fmt.Fprintln(w, `{"delete":{"title":"File:Example.jpg","reason":"Private data","logid":12}}`)
+	}))
+	defer ts.Close()
+	client, err := New(ts.URL, "TESTING")
+	if err != nil {
+		t.Fatalf("Error creating client: %s", err)
+	}
+
+	err = client.DeleteFileVersion("Example.jpg", "20160501100000!Example.jpg", "Private data")
+	if err != nil {
+		t.Fatalf("Error deleting file version: %s", err)
+	}
+	err = client.DeleteFileVersion("Example.jpg", "", "Private data")
+	if err == nil {
+		t.Fatal("Deleting without an archive name didn't fail")
+	}
+}
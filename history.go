@@ -0,0 +1,165 @@
+//
+// Copyright 2016 James McGuire
+//
+// This code is covered under the MIT License
+// Please refer to the LICENSE file in the root of this
+// repository for any information.
+
+package mediawiki
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The revision properties History requests when none are configured.
+var defaultHistoryProps = []string{"ids", "flags", "timestamp", "user", "userid", "size", "sha1", "comment", "tags"}
+
+// HistoryOptions configures History. The zero value lists every revision,
+// newest first, without their content.
+//
+// See https://www.mediawiki.org/wiki/API:Revisions
+type HistoryOptions struct {
+	// OldestFirst lists revisions oldest first instead of newest first.
+	OldestFirst bool
+
+	// Since and Until, if set, only list revisions made in that time
+	// range, inclusive.
+	Since time.Time
+	Until time.Time
+	// MinID and MaxID, if set, only list revisions with IDs in that
+	// range, inclusive. They can't be combined with Since and Until.
+	MinID int
+	MaxID int
+
+	// User only lists revisions made by that user and ExcludeUser leaves
+	// out revisions made by that user.
+	User        string
+	ExcludeUser string
+
+	// Props selects the rvprop values to request. Empty requests ids,
+	// flags, timestamp, user, userid, size, sha1, comment and tags.
+	Props []string
+	// Content requests the content of every revision as well, which makes
+	// the API return fewer revisions per request.
+	Content bool
+}
+
+// HistoryIterator walks through the revisions of a page, requesting more
+// as needed.
+//
+// Example:
+//
+//	iter := client.History("SOME PAGE", nil)
+//	for iter.Next() {
+//	    revision := iter.Revision()
+//	    // Do something with revision
+//	}
+//	if err := iter.Err(); err != nil {
+//	    // Handle the error
+//	}
+type HistoryIterator struct {
+	query    *QueryIterator
+	batch    []Revision
+	revision *Revision
+	err      error
+}
+
+// History returns a HistoryIterator over the revisions of the page title.
+// opts may be nil to use the defaults. The first batch is not requested
+// until Next is called.
+func (m *MWApi) History(title string, opts *HistoryOptions) *HistoryIterator {
+	return m.HistoryContext(context.Background(), title, opts)
+}
+
+// HistoryContext is like History but uses ctx for every batch requested by
+// the iterator.
+func (m *MWApi) HistoryContext(ctx context.Context, title string, opts *HistoryOptions) *HistoryIterator {
+	if opts == nil {
+		opts = &HistoryOptions{}
+	}
+	return &HistoryIterator{query: m.QueryContext(ctx, opts.values(title))}
+}
+
+// values converts the options in to API parameters.
+func (o *HistoryOptions) values(title string) map[string]string {
+	props := o.Props
+	if len(props) == 0 {
+		props = defaultHistoryProps
+	}
+	if o.Content {
+		props = append(props[:len(props):len(props)], "content")
+	}
+	values := map[string]string{
+		"prop":    "revisions",
+		"titles":  title,
+		"rvprop":  strings.Join(props, "|"),
+		"rvlimit": "max",
+	}
+
+	// rvstart is where the listing begins, so it is the newer bound when
+	// listing newest first.
+	start, end := o.Until, o.Since
+	startID, endID := o.MaxID, o.MinID
+	if o.OldestFirst {
+		values["rvdir"] = "newer"
+		start, end = end, start
+		startID, endID = endID, startID
+	}
+	if !start.IsZero() {
+		values["rvstart"] = formatTimestamp(start)
+	}
+	if !end.IsZero() {
+		values["rvend"] = formatTimestamp(end)
+	}
+	if startID != 0 {
+		values["rvstartid"] = strconv.Itoa(startID)
+	}
+	if endID != 0 {
+		values["rvendid"] = strconv.Itoa(endID)
+	}
+	if o.User != "" {
+		values["rvuser"] = o.User
+	}
+	if o.ExcludeUser != "" {
+		values["rvexcludeuser"] = o.ExcludeUser
+	}
+	return values
+}
+
+// Next advances to the next revision. It returns false once the revisions
+// are exhausted or an error occurs, which can be checked with Err.
+func (h *HistoryIterator) Next() bool {
+	for len(h.batch) == 0 {
+		if h.err != nil || !h.query.Next() {
+			h.revision = nil
+			return false
+		}
+		response, err := h.query.Response()
+		if err != nil {
+			h.err = err
+			continue
+		}
+		for _, page := range response.Query.Pages {
+			h.batch = append(h.batch, page.Revisions...)
+		}
+	}
+	h.revision = &h.batch[0]
+	h.batch = h.batch[1:]
+	return true
+}
+
+// Revision returns the current revision.
+func (h *HistoryIterator) Revision() *Revision {
+	return h.revision
+}
+
+// Err returns the first error encountered while iterating, if any.
+func (h *HistoryIterator) Err() error {
+	if h.err != nil {
+		return h.err
+	}
+	return h.query.Err()
+}
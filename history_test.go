@@ -0,0 +1,91 @@
+package mediawiki
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+const (
+	historyFirst  = `{"continue":{"rvcontinue":"20160101000000|3","continue":"||"},"query":{"pages":{"12":{"pageid":12,"ns":0,"title":"Example","revisions":[{"revid":5,"parentid":4,"user":"Alice","timestamp":"2016-03-01T00:00:00Z","comment":"Fix typo","tags":["mobile edit"]},{"revid":4,"parentid":3,"minor":"","user":"Bob","timestamp":"2016-02-01T00:00:00Z","comment":"Expand"}]}}}}`
+	historySecond = `{"batchcomplete":"","query":{"pages":{"12":{"pageid":12,"ns":0,"title":"Example","revisions":[{"revid":3,"parentid":0,"user":"Alice","timestamp":"2016-01-01T00:00:00Z","comment":"Create"}]}}}}`
+)
+
+func TestHistory(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		err := r.ParseForm()
+		if err != nil {
+			panic(err)
+		}
+		if r.Form.Get("titles") != "Example" || r.Form.Get("rvlimit") != "max" ||
+			r.Form.Get("rvprop") != "ids|flags|timestamp|user|userid|size|sha1|comment|tags" {
+			t.Errorf("Bad history request: %v", r.Form)
+		}
+		if r.Form.Get("rvcontinue") == "" {
+			fmt.Fprintln(w, historyFirst)
+		} else {
+			fmt.Fprintln(w, historySecond)
+		}
+	}))
+	defer ts.Close()
+	client, err := New(ts.URL, "TESTING")
+	if err != nil {
+		t.Fatalf("Error creating client: %s", err)
+	}
+
+	var revids []int
+	iter := client.History("Example", nil)
+	for iter.Next() {
+		revision := iter.Revision()
+		revids = append(revids, revision.Revid)
+		if minor := revision.Revid == 4; bool(revision.IsMinor) != minor {
+			t.Errorf("Revision %d has IsMinor %t", revision.Revid, revision.IsMinor)
+		}
+	}
+	if err := iter.Err(); err != nil {
+		t.Fatalf("Error walking history: %s", err)
+	}
+	if len(revids) != 3 || revids[0] != 5 || revids[1] != 4 || revids[2] != 3 {
+		t.Errorf("Wrong revisions: %v", revids)
+	}
+}
+
+func TestHistoryOptions(t *testing.T) {
+	since := time.Date(2016, 1, 1, 0, 0, 0, 0, time.UTC)
+	until := time.Date(2016, 2, 1, 0, 0, 0, 0, time.UTC)
+
+	values := (&HistoryOptions{Since: since, Until: until, User: "Alice", Content: true}).values("Example")
+	if values["rvstart"] != "2016-02-01T00:00:00Z" || values["rvend"] != "2016-01-01T00:00:00Z" || values["rvdir"] != "" {
+		t.Errorf("Wrong range for newest first: %v", values)
+	}
+	if values["rvuser"] != "Alice" || values["rvprop"] != "ids|flags|timestamp|user|userid|size|sha1|comment|tags|content" {
+		t.Errorf("Wrong parameters: %v", values)
+	}
+
+	opts := &HistoryOptions{OldestFirst: true, MinID: 3, MaxID: 9, ExcludeUser: "Bot", Props: []string{"ids"}}
+	values = opts.values("Example")
+	if values["rvdir"] != "newer" || values["rvstartid"] != "3" || values["rvendid"] != "9" {
+		t.Errorf("Wrong range for oldest first: %v", values)
+	}
+	if values["rvexcludeuser"] != "Bot" || values["rvprop"] != "ids" {
+		t.Errorf("Wrong parameters: %v", values)
+	}
+	if len(defaultHistoryProps) != 9 {
+		t.Errorf("Default props were modified: %v", defaultHistoryProps)
+	}
+}
+
+func TestHistoryError(t *testing.T) {
+	test := BuildUp(mwerror, t)
+	defer test.TearDown()
+
+	iter := test.client.History("Example", nil)
+	if iter.Next() {
+		t.Fatal("Next returned true for an error response")
+	}
+	if iter.Err() == nil {
+		t.Fatal("No error returned")
+	}
+}
@@ -0,0 +1,237 @@
+//
+// Copyright 2016 James McGuire
+//
+// This code is covered under the MIT License
+// Please refer to the LICENSE file in the root of this
+// repository for any information.
+
+package mediawiki
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+)
+
+// Status values returned by action=clientlogin.
+const (
+	LoginPass     = "PASS"
+	LoginFail     = "FAIL"
+	LoginUI       = "UI"
+	LoginRedirect = "REDIRECT"
+	LoginRestart  = "RESTART"
+)
+
+// Values for MWApi.Assert.
+const (
+	AssertUser = "user"
+	AssertBot  = "bot"
+)
+
+// Returned by relogin when Login or ClientLogin haven't been called, for
+// example after LoadSession.
+var errNoCredentials = errors.New("no stored credentials to log in again with")
+
+// The number of UI or REDIRECT rounds ClientLogin will go through before
+// giving up.
+const maxLoginRounds = 10
+
+// LoginPrompt describes additional information the server needs before a
+// ClientLogin can complete, for example a two-factor authentication code.
+type LoginPrompt struct {
+	// Status is either LoginUI or LoginRedirect.
+	Status string
+	// Message is the human readable explanation from the server.
+	Message     string
+	MessageCode string
+	// RedirectTarget is the URL the user must visit when Status is
+	// LoginRedirect.
+	RedirectTarget string
+	// Requests lists the authentication requests and their fields.
+	Requests []AuthRequest
+}
+
+// AuthRequest is one of the authentication requests in a LoginPrompt.
+type AuthRequest struct {
+	ID       string
+	Provider string
+	Account  string
+	Required string
+	Fields   map[string]AuthField
+}
+
+// AuthField is a single input requested by an AuthRequest. The map key in
+// AuthRequest.Fields is the name that the value must be returned under.
+type AuthField struct {
+	Type      string
+	Label     string
+	Help      string
+	Value     string
+	Optional  bool
+	Sensitive bool
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+//
+// Boolean fields may be returned as true or, in the legacy format, as an
+// empty string whose presence means true.
+func (f *AuthField) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		Type      string
+		Label     string
+		Help      string
+		Value     string
+		Optional  json.RawMessage
+		Sensitive json.RawMessage
+	}
+	err := json.Unmarshal(data, &raw)
+	if err != nil {
+		return err
+	}
+	*f = AuthField{
+		Type:      raw.Type,
+		Label:     raw.Label,
+		Help:      raw.Help,
+		Value:     raw.Value,
+		Optional:  jsonBool(raw.Optional),
+		Sensitive: jsonBool(raw.Sensitive),
+	}
+	return nil
+}
+
+// Reports whether a raw boolean from the API is set.
+func jsonBool(raw json.RawMessage) bool {
+	return len(raw) > 0 && string(raw) != "false" && string(raw) != "null"
+}
+
+// LoginPromptFunc is called by ClientLogin when the server asks for more
+// information. It returns the values for the requested fields, keyed by
+// field name.
+type LoginPromptFunc func(prompt *LoginPrompt) (map[string]string, error)
+
+// Unmarshal clientlogin data...
+type outerClientLogin struct {
+	ClientLogin struct {
+		Status         string
+		Message        string
+		MessageCode    string
+		RedirectTarget string
+		Username       string
+		Requests       []AuthRequest
+	}
+}
+
+// Translate an unsuccessful clientlogin response in to an *APIError.
+func (r *outerClientLogin) err() error {
+	code := r.ClientLogin.MessageCode
+	if code == "" {
+		code = r.ClientLogin.Status
+	}
+	return &APIError{Code: code, Info: r.ClientLogin.Message, Module: "clientlogin"}
+}
+
+// ClientLogin logs in to the Mediawiki Website using action=clientlogin,
+// which accepts main account passwords.
+//
+// If the server needs more information, such as a two-factor
+// authentication code, prompt is called with the details and the login is
+// continued with the values it returns. prompt may be nil if no further
+// information is expected.
+//
+// See https://www.mediawiki.org/wiki/API:Login#Method_2._clientlogin
+func (m *MWApi) ClientLogin(username, password string, prompt LoginPromptFunc) error {
+	return m.ClientLoginContext(context.Background(), username, password, prompt)
+}
+
+// ClientLoginContext is like ClientLogin but uses ctx for the login
+// requests.
+func (m *MWApi) ClientLoginContext(ctx context.Context, username, password string, prompt LoginPromptFunc) error {
+	if username == "" {
+		return errors.New("empty username supplied")
+	}
+	if password == "" {
+		return errors.New("empty password supplied")
+	}
+
+	token, err := m.TokenContext(ctx, LoginToken)
+	if err != nil {
+		return err
+	}
+
+	query := map[string]string{
+		"action":         "clientlogin",
+		"username":       username,
+		"password":       password,
+		"logintoken":     token,
+		"loginreturnurl": m.url.String(),
+	}
+
+	for round := 0; round < maxLoginRounds; round++ {
+		body, err := m.APIContext(ctx, query)
+		if err != nil {
+			return err
+		}
+
+		var response outerClientLogin
+		err = json.Unmarshal(body, &response)
+		if err != nil {
+			return err
+		}
+		result := response.ClientLogin
+
+		switch result.Status {
+		case LoginPass:
+			m.mu.Lock()
+			m.username = username
+			m.password = password
+			m.clientLogin = true
+			m.prompt = prompt
+			m.mu.Unlock()
+			m.setUser(result.Username, username)
+			m.clearTokens()
+			return nil
+		case LoginUI, LoginRedirect:
+			if prompt == nil {
+				return response.err()
+			}
+			values, err := prompt(&LoginPrompt{
+				Status:         result.Status,
+				Message:        result.Message,
+				MessageCode:    result.MessageCode,
+				RedirectTarget: result.RedirectTarget,
+				Requests:       result.Requests,
+			})
+			if err != nil {
+				return err
+			}
+			query = map[string]string{
+				"action":        "clientlogin",
+				"logincontinue": "1",
+				"logintoken":    token,
+			}
+			for key, value := range values {
+				query[key] = value
+			}
+		default:
+			return response.err()
+		}
+	}
+	return errors.New("too many clientlogin rounds")
+}
+
+// Log in again with the credentials from the last call to Login or
+// ClientLogin, after the session has expired.
+func (m *MWApi) relogin(ctx context.Context) error {
+	m.mu.Lock()
+	username, password := m.username, m.password
+	clientLogin, prompt := m.clientLogin, m.prompt
+	m.mu.Unlock()
+
+	if username == "" || password == "" {
+		return errNoCredentials
+	}
+	if clientLogin {
+		return m.ClientLoginContext(ctx, username, password, prompt)
+	}
+	return m.LoginContext(ctx, username, password)
+}
@@ -0,0 +1,303 @@
+package mediawiki
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const (
+	loginToken        = `{"batchcomplete":"","query":{"tokens":{"logintoken":"LOGINTOKEN+\\"}}}`
+	clientLoginUI     = `{"clientlogin":{"status":"UI","message":"Two-factor authentication required.","messagecode":"oathauth-auth-ui","requests":[{"id":"TOTPAuthenticationRequest","metadata":{},"required":"required","provider":"","account":"","fields":{"OATHToken":{"type":"string","label":"Token","help":"Enter the code from your app.","sensitive":""}}}]}}`
+	clientLoginPass   = `{"clientlogin":{"status":"PASS","username":"Asdf"}}`
+	clientLoginFailed = `{"clientlogin":{"status":"FAIL","message":"Incorrect username or password entered.","messagecode":"wrongpassword"}}`
+)
+
+func TestLoginMetaToken(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		err := r.ParseForm()
+		if err != nil {
+			panic(err)
+		}
+		if r.Form.Get("meta") == "tokens" {
+			if r.Form.Get("type") != LoginToken {
+				t.Errorf("Wrong token type requested: %s", r.Form.Get("type"))
+			}
+			fmt.Fprintln(w, loginToken)
+			return
+		}
+		if r.Form.Get("lgtoken") != `LOGINTOKEN+\` {
+			t.Errorf("Login token was not sent with the first request: %v", r.Form)
+			fmt.Fprintln(w, firstLogin)
+			return
+		}
+		fmt.Fprintln(w, secondLogin)
+	}))
+	defer ts.Close()
+	client, err := New(ts.URL, "TESTING")
+	if err != nil {
+		t.Fatalf("Error creating client: %s", err)
+	}
+	err = client.Login("asdf", "asdf")
+	if err != nil {
+		t.Fatalf("Client failed to login: %s", err)
+	}
+}
+
+func TestClientLoginTwoFactor(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		err := r.ParseForm()
+		if err != nil {
+			panic(err)
+		}
+		switch {
+		case r.Form.Get("meta") == "tokens":
+			fmt.Fprintln(w, loginToken)
+		case r.Form.Get("action") != "clientlogin" || r.Form.Get("logintoken") != `LOGINTOKEN+\`:
+			t.Errorf("Bad clientlogin request: %v", r.Form)
+		case r.Form.Get("logincontinue") == "1":
+			if r.Form.Get("OATHToken") != "123456" {
+				fmt.Fprintln(w, clientLoginFailed)
+				return
+			}
+			fmt.Fprintln(w, clientLoginPass)
+		default:
+			if r.Form.Get("username") != "asdf" || r.Form.Get("password") != "jkl" || r.Form.Get("loginreturnurl") == "" {
+				t.Errorf("Bad initial clientlogin request: %v", r.Form)
+			}
+			fmt.Fprintln(w, clientLoginUI)
+		}
+	}))
+	defer ts.Close()
+	client, err := New(ts.URL, "TESTING")
+	if err != nil {
+		t.Fatalf("Error creating client: %s", err)
+	}
+
+	prompted := false
+	err = client.ClientLogin("asdf", "jkl", func(prompt *LoginPrompt) (map[string]string, error) {
+		prompted = true
+		if prompt.Status != LoginUI || len(prompt.Requests) != 1 {
+			t.Errorf("Unexpected prompt: %#v", prompt)
+		}
+		field := prompt.Requests[0].Fields["OATHToken"]
+		if field.Label != "Token" || !field.Sensitive || field.Optional {
+			t.Errorf("Field not parsed correctly: %#v", field)
+		}
+		return map[string]string{"OATHToken": "123456"}, nil
+	})
+	if err != nil {
+		t.Fatalf("ClientLogin failed: %s", err)
+	}
+	if !prompted {
+		t.Error("Prompt was never called")
+	}
+}
+
+func TestClientLoginFailed(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		err := r.ParseForm()
+		if err != nil {
+			panic(err)
+		}
+		if r.Form.Get("meta") == "tokens" {
+			fmt.Fprintln(w, loginToken)
+			return
+		}
+		fmt.Fprintln(w, clientLoginFailed)
+	}))
+	defer ts.Close()
+	client, err := New(ts.URL, "TESTING")
+	if err != nil {
+		t.Fatalf("Error creating client: %s", err)
+	}
+	err = client.ClientLogin("asdf", "jkl", nil)
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.Code != "wrongpassword" || apiErr.Module != "clientlogin" {
+		t.Fatalf("Expected wrongpassword error, got: %v", err)
+	}
+
+}
+
+func TestClientLoginNoPrompt(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		err := r.ParseForm()
+		if err != nil {
+			panic(err)
+		}
+		if r.Form.Get("meta") == "tokens" {
+			fmt.Fprintln(w, loginToken)
+			return
+		}
+		fmt.Fprintln(w, clientLoginUI)
+	}))
+	defer ts.Close()
+	client, err := New(ts.URL, "TESTING")
+	if err != nil {
+		t.Fatalf("Error creating client: %s", err)
+	}
+	err = client.ClientLogin("asdf", "jkl", nil)
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.Code != "oathauth-auth-ui" {
+		t.Fatalf("Expected an error for a UI response without a prompt, got: %v", err)
+	}
+}
+
+func TestAutoRelogin(t *testing.T) {
+	logins := 0
+	edits := 0
+	session := "FIRST"
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		err := r.ParseForm()
+		if err != nil {
+			panic(err)
+		}
+		cookie, _ := r.Cookie("wikidb_session")
+		loggedIn := cookie != nil && cookie.Value == session
+		switch {
+		case r.Form.Get("meta") == "tokens":
+			fmt.Fprintln(w, editTokenReponse)
+		case r.Form.Get("action") == "login":
+			logins++
+			if r.Form.Get("lgname") != "Asdf" || r.Form.Get("lgpassword") != "jkl" {
+				t.Errorf("Wrong credentials used to log in: %v", r.Form)
+			}
+			http.SetCookie(w, &http.Cookie{Name: "wikidb_session", Value: session, Path: "/"})
+			fmt.Fprintln(w, secondLogin)
+		case r.Form.Get("action") == "edit":
+			edits++
+			if r.Form.Get("assert") != AssertUser {
+				t.Errorf("assert was not sent with the edit: %v", r.Form)
+			}
+			if !loggedIn {
+				fmt.Fprintln(w, `{"error":{"code":"assertuserfailed","info":"You are no longer logged in."}}`)
+				return
+			}
+			fmt.Fprintln(w, editsuccess)
+		default:
+			t.Errorf("Unexpected request: %v", r.Form)
+		}
+	}))
+	defer ts.Close()
+	client, err := New(ts.URL, "TESTING")
+	if err != nil {
+		t.Fatalf("Error creating client: %s", err)
+	}
+	client.Assert = AssertUser
+	err = client.Login("Asdf", "jkl")
+	if err != nil {
+		t.Fatalf("Error logging in: %s", err)
+	}
+
+	// Expire the session on the server.
+	session = "SECOND"
+	err = client.Edit(map[string]string{"title": "somepage"})
+	if !errors.Is(err, ErrAssertUserFailed) {
+		t.Fatalf("Expected assertuserfailed without AutoRelogin, got: %v", err)
+	}
+
+	client.AutoRelogin = true
+	err = client.Edit(map[string]string{"title": "somepage"})
+	if err != nil {
+		t.Fatalf("Edit was not replayed after logging in again: %s", err)
+	}
+	if logins != 2 || edits != 3 {
+		t.Errorf("Expected 2 logins and 3 edits, got %d and %d", logins, edits)
+	}
+}
+
+func TestAutoReloginNoCredentials(t *testing.T) {
+	test := BuildUp(`{"error":{"code":"assertbotfailed","info":"You do not have the bot right."}}`, t)
+	defer test.TearDown()
+	test.client.tokens[CSRFToken] = "asdf"
+	test.client.AutoRelogin = true
+	test.client.Assert = AssertBot
+	err := test.client.Edit(map[string]string{"title": "somepage"})
+	if !errors.Is(err, ErrAssertBotFailed) {
+		t.Fatalf("Expected assertbotfailed without credentials to log in again with, got: %v", err)
+	}
+}
+
+func TestAutoReloginFailed(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		err := r.ParseForm()
+		if err != nil {
+			panic(err)
+		}
+		switch r.Form.Get("action") {
+		case "login":
+			fmt.Fprintln(w, `{"login":{"result":"Failed","reason":"Incorrect password entered."}}`)
+		case "edit":
+			fmt.Fprintln(w, `{"error":{"code":"assertuserfailed","info":"You are no longer logged in."}}`)
+		default:
+			fmt.Fprintln(w, editTokenReponse)
+		}
+	}))
+	defer ts.Close()
+	client, err := New(ts.URL, "TESTING")
+	if err != nil {
+		t.Fatalf("Error creating client: %s", err)
+	}
+	client.username, client.password = "Asdf", "changed"
+	client.AutoRelogin = true
+
+	err = client.Edit(map[string]string{"title": "somepage"})
+	if !errors.Is(err, ErrAssertUserFailed) {
+		t.Fatalf("Expected assertuserfailed, got: %v", err)
+	}
+	if !strings.Contains(err.Error(), "Incorrect password") {
+		t.Errorf("Login error is missing from: %s", err)
+	}
+}
+
+func TestAutoReloginAfterLogout(t *testing.T) {
+	logins := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		err := r.ParseForm()
+		if err != nil {
+			panic(err)
+		}
+		switch r.Form.Get("action") {
+		case "login":
+			logins++
+			fmt.Fprintln(w, secondLogin)
+		case "logout":
+			fmt.Fprintln(w, `{}`)
+		case "edit":
+			fmt.Fprintln(w, `{"error":{"code":"assertuserfailed","info":"You are no longer logged in."}}`)
+		default:
+			fmt.Fprintln(w, editTokenReponse)
+		}
+	}))
+	defer ts.Close()
+	client, err := New(ts.URL, "TESTING")
+	if err != nil {
+		t.Fatalf("Error creating client: %s", err)
+	}
+	client.Assert = AssertUser
+	client.AutoRelogin = true
+	err = client.Login("Asdf", "jkl")
+	if err != nil {
+		t.Fatalf("Error logging in: %s", err)
+	}
+
+	client.Logout()
+	err = client.Edit(map[string]string{"title": "somepage"})
+	if !errors.Is(err, ErrAssertUserFailed) {
+		t.Fatalf("Expected assertuserfailed after logging out, got: %v", err)
+	}
+	if logins != 1 {
+		t.Errorf("Logged in again after Logout, %d logins", logins)
+	}
+	var saved strings.Builder
+	err = client.SaveSession(&saved)
+	if err != nil {
+		t.Fatalf("Error saving the session: %s", err)
+	}
+	if !strings.Contains(saved.String(), `"username":""`) {
+		t.Errorf("Session still records the user after Logout: %s", saved.String())
+	}
+}
@@ -18,50 +18,78 @@
 package mediawiki
 
 import (
-	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
-	"io"
 	"io/ioutil"
-	"mime/multipart"
 	"net/http"
 	"net/http/cookiejar"
 	"net/url"
 	"strings"
+	"sync"
 	"time"
 )
 
 // MWApi is used to interact with the MediaWiki server.
+//
+// An MWApi is safe for concurrent use by multiple goroutines. The exported
+// fields configure the client and should be set before it is shared; they
+// must not be changed while requests are in flight.
 type MWApi struct {
-	username      string
-	password      string
-	Domain        string
-	userAgent     string
-	url           *url.URL
-	client        *http.Client
-	format        string
-	edittoken     string
-	UseBasicAuth  bool
-	BasicAuthUser string
-	BasicAuthPass string
+	// mu guards the session state: the credentials, how they were used to
+	// log in, the canonical name of the logged in user and cached tokens.
+	mu          sync.Mutex
+	username    string
+	password    string
+	user        string
+	clientLogin bool
+	prompt      LoginPromptFunc
+	Domain      string
+	userAgent   string
+	url         *url.URL
+	client      *http.Client
+	format      string
+	tokens      map[string]string
+
+	// Auth, if set, adds credentials to every request, for example HTTP
+	// basic authentication or OAuth. It is independent of Login.
+	Auth Authenticator
+	// OnWarning, if set, is called for every warning returned by the API.
+	OnWarning func(Warning)
+	// OnUploadProgress, if set, is called as the contents of an uploaded
+	// file are sent, with the number of bytes of the file sent so far. The
+	// count starts over when a request is retried.
+	OnUploadProgress func(filename string, sent int64)
+	// Retry, if set, controls how failed requests are retried. By default
+	// every request is attempted once.
+	Retry *RetryPolicy
+	// ReadLimiter and WriteLimiter, if set, throttle requests sent to the
+	// API. WriteLimiter applies to modules that modify the wiki such as
+	// edit, upload and move, ReadLimiter applies to everything else.
+	ReadLimiter  Limiter
+	WriteLimiter Limiter
+	// Assert, if set to AssertUser or AssertBot, is sent as the assert
+	// parameter with every write so that the server refuses to make edits
+	// once the session has expired.
+	Assert string
+	// AutoRelogin logs in again with the credentials given to Login or
+	// ClientLogin and replays the write once when an assertion fails.
+	AutoRelogin bool
+	// TransformAttempts limits how many times Transform reads and edits a
+	// page when its edits conflict. Zero means 3.
+	TransformAttempts int
+	// FollowRedirects makes Read and ReadMany return the page a redirect
+	// points to instead of the redirect itself.
+	FollowRedirects bool
 }
 
 // Unmarshal login data...
 type outerLogin struct {
 	Login struct {
-		Result string
-		Token  string
-	}
-}
-
-// Unmarshall response from page edits...
-type outerEdit struct {
-	Edit struct {
-		Result   string
-		PageId   int
-		Title    string
-		OldRevId int
-		NewRevId int
+		Result     string
+		Reason     string
+		Token      string
+		LgUsername string
 	}
 }
 
@@ -74,7 +102,58 @@ type Response struct {
 		// As a workaround you can use PageSlice which will create
 		// a list of pages from the map.
 		Pages map[string]Page
+		// Normalized lists the requested titles that were changed to
+		// their canonical form, for example "main page" to "Main Page".
+		Normalized []TitleChange
+		// Converted lists titles that were converted to another
+		// language variant to find the page.
+		Converted []TitleChange
+		// Redirects lists the redirects that were followed, when
+		// requested with redirects.
+		Redirects []Redirect
+	}
+	Warnings Warnings
+	// CurTimestamp is the time on the server, when requested with
+	// curtimestamp.
+	CurTimestamp time.Time
+}
+
+// TitleChange maps a requested title to the title the API used instead.
+type TitleChange struct {
+	From string
+	To   string
+}
+
+// Redirect maps the title of a redirect to the page it points to.
+type Redirect struct {
+	From string
+	To   string
+	// ToFragment is the section of the target page, if the redirect
+	// points to one.
+	ToFragment string
+}
+
+// Resolve returns the title of the page that was returned for a requested
+// title, after normalization, variant conversion and redirects, and the
+// section that a followed redirect pointed to.
+func (r *Response) Resolve(title string) (resolved, fragment string) {
+	resolved = title
+	for _, change := range r.Query.Normalized {
+		if change.From == resolved {
+			resolved = change.To
+		}
 	}
+	for _, change := range r.Query.Converted {
+		if change.From == resolved {
+			resolved = change.To
+		}
+	}
+	for _, redirect := range r.Query.Redirects {
+		if redirect.From == resolved {
+			resolved, fragment = redirect.To, redirect.ToFragment
+		}
+	}
+	return resolved, fragment
 }
 
 // PageSlice generates a slice from Pages to work around the sillyness in
@@ -100,51 +179,93 @@ type Page struct {
 	Counter   interface{}
 	Length    int
 	Edittoken string
-	Revisions []struct {
-		Revid         int       `json:"revid"`
-		Parentid      int       `json:"parentid"`
-		Minor         string    `json:"minor"`
-		User          string    `json:"user"`
-		Userid        int       `json:"userid"`
-		Timestamp     time.Time `json:"timestamp"`
-		Size          int       `json:"size"`
-		Sha1          string    `json:"sha1"`
-		ContentModel  string    `json:"contentmodel"`
-		Comment       string    `json:"comment"`
-		ParsedComment string    `json:"parsedcomment"`
-		ContentFormat string    `json:"contentformat"`
-		Body          string    `json:"*"` // Take note, MediaWiki literally returns { '*':
-	}
-	Imageinfo []struct {
-		Url            string
-		Descriptionurl string
-	}
+	// Missing is set if the page doesn't exist, and Invalid if the title
+	// isn't a valid page title, for the reason in InvalidReason.
+	Missing       Flag
+	Invalid       Flag
+	InvalidReason string
+	Revisions     []Revision
+	Imageinfo     []ImageInfo
 }
 
-type mwError struct {
-	Error struct {
-		Code string
-		Info string
-	}
+// Flag is a boolean returned by the API. In the default format flags are
+// set by being present, with an empty string as the value.
+type Flag bool
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (f *Flag) UnmarshalJSON(data []byte) error {
+	*f = Flag(jsonBool(data))
+	return nil
 }
 
-type uploadResponse struct {
-	Upload struct {
-		Result string
-	}
+// A Revision is a single version of a page. Only the properties that were
+// requested with rvprop are set.
+type Revision struct {
+	Revid         int       `json:"revid"`
+	Parentid      int       `json:"parentid"`
+	Minor         string    `json:"minor"`
+	User          string    `json:"user"`
+	Userid        int       `json:"userid"`
+	Timestamp     time.Time `json:"timestamp"`
+	Size          int       `json:"size"`
+	Sha1          string    `json:"sha1"`
+	ContentModel  string    `json:"contentmodel"`
+	Comment       string    `json:"comment"`
+	ParsedComment string    `json:"parsedcomment"`
+	ContentFormat string    `json:"contentformat"`
+	Tags          []string  `json:"tags"`
+	Body          string    `json:"*"` // Take note, MediaWiki literally returns { '*':
+	// IsMinor is set for minor edits, when requested with rvprop=flags.
+	// Minor holds the raw value, which is empty either way.
+	IsMinor Flag `json:"-"`
 }
 
-// Helper function for translating MediaWiki errors in to Golang errors.
-func checkError(response []byte) error {
-	var mwerror mwError
-	err := json.Unmarshal(response, &mwerror)
+// UnmarshalJSON implements json.Unmarshaler.
+func (r *Revision) UnmarshalJSON(data []byte) error {
+	// Decode the fields as usual, without recursing in to this method.
+	type revision Revision
+	err := json.Unmarshal(data, (*revision)(r))
 	if err != nil {
-		return nil
-	} else if mwerror.Error.Code != "" {
-		return errors.New(mwerror.Error.Code + ": " + mwerror.Error.Info)
-	} else {
-		return nil
+		return err
+	}
+	var flags struct {
+		Minor Flag
+	}
+	err = json.Unmarshal(data, &flags)
+	if err != nil {
+		return err
 	}
+	r.IsMinor = flags.Minor
+	return nil
+}
+
+// ImageInfo describes a version of a file, as returned by prop=imageinfo
+// and by uploads. Only the properties that were requested are set.
+type ImageInfo struct {
+	Timestamp      time.Time `json:"timestamp"`
+	User           string    `json:"user"`
+	Size           int64     `json:"size"`
+	Width          int       `json:"width"`
+	Height         int       `json:"height"`
+	Comment        string    `json:"comment"`
+	Url            string    `json:"url"`
+	Descriptionurl string    `json:"descriptionurl"`
+	Sha1           string    `json:"sha1"`
+	Mime           string    `json:"mime"`
+	Mediatype      string    `json:"mediatype"`
+	// The scaled version of the file requested with iiurlwidth or
+	// iiurlheight.
+	ThumbUrl    string `json:"thumburl"`
+	ThumbWidth  int    `json:"thumbwidth"`
+	ThumbHeight int    `json:"thumbheight"`
+	// ArchiveName identifies an old version of the file, it is empty for
+	// the current version.
+	ArchiveName string `json:"archivename"`
+	// ExtMetadata holds formatted metadata such as the license, artist
+	// and credit, keyed by property name.
+	ExtMetadata map[string]ExtMetadataValue `json:"extmetadata"`
+	// Metadata holds the raw metadata of the file, such as EXIF tags.
+	Metadata []MetadataItem `json:"metadata"`
 }
 
 // New generates a new MediaWiki API (MWApi) struct.
@@ -172,189 +293,137 @@ func New(wikiURL, userAgent string) (*MWApi, error) {
 		url:       clientURL,
 		client:    &client,
 		format:    "json",
+		tokens:    map[string]string{},
 		userAgent: "mediawiki (Golang) https://github.com/sadbox/mediawiki " + userAgent,
 	}, nil
 }
 
 // This will automatically add the user agent and encode the http request properly
-func (m *MWApi) postForm(query url.Values) ([]byte, error) {
-	request, err := http.NewRequest("POST", m.url.String(), strings.NewReader(query.Encode()))
-	if err != nil {
-		return nil, err
-	}
-	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
-	request.Header.Set("user-agent", m.userAgent)
-	if m.UseBasicAuth {
-		request.SetBasicAuth(m.BasicAuthUser, m.BasicAuthPass)
+func (m *MWApi) postForm(ctx context.Context, query url.Values) ([]byte, error) {
+	if maxlag := m.maxLag(); maxlag != "" {
+		query.Set("maxlag", maxlag)
 	}
-	resp, err := m.client.Do(request)
-	if err != nil {
-		return nil, err
+	if m.Assert != "" && writeModules[query.Get("action")] {
+		query.Set("assert", m.Assert)
 	}
-	defer resp.Body.Close()
-
-	body, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return nil, err
-	}
-	if err = checkError(body); err != nil {
-		return nil, err
-	}
-
-	return body, nil
-}
-
-// Download a file.
-//
-// Returns a readcloser that must be closed manually. Refer to the
-// example app for additional usage.
-func (m *MWApi) Download(filename string) (io.ReadCloser, error) {
-	// First get the direct url of the file
-	query := map[string]string{
-		"action": "query",
-		"prop":   "imageinfo",
-		"iiprop": "url",
-		"titles": filename,
-	}
-
-	body, err := m.API(query)
-	if err != nil {
-		return nil, err
-	}
-
-	var response Response
-	err = json.Unmarshal(body, &response)
-	if err != nil {
-		return nil, err
-	}
-	pl := response.PageSlice()
-
-	if len(pl) < 1 {
-		return nil, errors.New("no file found")
-	}
-	page := pl[0]
-	if len(page.Imageinfo) < 1 {
-		return nil, errors.New("no file found")
-	}
-	fileurl := page.Imageinfo[0].Url
-
-	// Then return the body of the response
-	request, err := http.NewRequest("GET", fileurl, nil)
-	if err != nil {
-		return nil, err
-	}
-	request.Header.Set("user-agent", m.userAgent)
-	if m.UseBasicAuth {
-		request.SetBasicAuth(m.BasicAuthUser, m.BasicAuthPass)
-	}
-
-	resp, err := m.client.Do(request)
-	if err != nil {
-		return nil, err
-	}
-	return resp.Body, nil
+	encoded := query.Encode()
+	return m.do(ctx, query.Get("action"), query, func() (*http.Request, error) {
+		request, err := http.NewRequestWithContext(ctx, "POST", m.url.String(), strings.NewReader(encoded))
+		if err != nil {
+			return nil, err
+		}
+		request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		return request, nil
+	})
 }
 
-// Upload a file
-//
-// This does a simple, but more error-prone upload. Mediawiki
-// has a chunked upload version but it is only available in newer
-// versions of the API.
-//
-// Automatically retrieves an edit token if necessary.
-func (m *MWApi) Upload(dstFilename string, file io.Reader) error {
-	if m.edittoken == "" {
-		err := m.GetEditToken()
+// Send the request built by newRequest, retrying according to the retry
+// policy. newRequest is called once per attempt so that every attempt gets
+// a fresh body. The user agent and credentials are added automatically, and
+// every attempt waits for the rate limiter that applies to module. form is
+// passed to the Authenticator and must hold the parameters of url-encoded
+// bodies.
+func (m *MWApi) do(ctx context.Context, module string, form url.Values, newRequest func() (*http.Request, error)) ([]byte, error) {
+	for attempt := 1; ; attempt++ {
+		if err := m.throttle(ctx, module); err != nil {
+			return nil, err
+		}
+		request, err := newRequest()
 		if err != nil {
-			return err
+			return nil, err
 		}
-	}
-
-	query := map[string]string{
-		"action":   "upload",
-		"filename": dstFilename,
-		"token":    m.edittoken,
-		"format":   m.format,
-	}
-
-	buffer := &bytes.Buffer{}
-	writer := multipart.NewWriter(buffer)
-
-	for key, value := range query {
-		err := writer.WriteField(key, value)
+		err = m.authenticate(request, form)
 		if err != nil {
-			return err
+			return nil, err
 		}
-	}
 
-	part, err := writer.CreateFormFile("file", dstFilename)
-	_, err = io.Copy(part, file)
-	if err != nil {
-		return err
-	}
+		var body []byte
+		var retryAfter string
+		resp, err := m.client.Do(request)
+		if err == nil {
+			retryAfter = resp.Header.Get("Retry-After")
+			body, err = m.readResponse(resp, module)
+		}
+		if err == nil {
+			return body, nil
+		}
 
-	err = writer.Close()
-	if err != nil {
-		return err
+		if m.Retry == nil || attempt >= m.Retry.MaxAttempts || !m.Retry.retryable(err) {
+			return nil, err
+		}
+		if err := sleep(ctx, m.Retry.delay(attempt, retryAfter)); err != nil {
+			return nil, err
+		}
 	}
+}
 
-	request, err := http.NewRequest("POST", m.url.String(), buffer)
-	if err != nil {
-		return err
-	}
-	request.Header.Set("Content-Type", writer.FormDataContentType())
+// Set the user agent and add any credentials from the Authenticator.
+func (m *MWApi) authenticate(request *http.Request, form url.Values) error {
 	request.Header.Set("user-agent", m.userAgent)
-	if m.UseBasicAuth {
-		request.SetBasicAuth(m.BasicAuthUser, m.BasicAuthPass)
+	if m.Auth == nil {
+		return nil
 	}
+	return m.Auth.Authenticate(request, form)
+}
 
-	resp, err := m.client.Do(request)
-	if err != nil {
-		return err
-	}
+// Read and close an API response, translating any errors and passing any
+// warnings to the OnWarning hook.
+func (m *MWApi) readResponse(resp *http.Response, module string) ([]byte, error) {
 	defer resp.Body.Close()
+
 	body, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
-		return err
+		return nil, err
 	}
-
-	if err = checkError(body); err != nil {
-		return err
+	m.handleWarnings(body)
+	if err = checkError(body, resp.StatusCode, module); err != nil {
+		return nil, err
 	}
 
-	var response uploadResponse
-	err = json.Unmarshal(body, &response)
-	if err != nil {
-		return err
-	}
-	if !(response.Upload.Result == "Success" || response.Upload.Result == "Warning") {
-		return errors.New(response.Upload.Result)
-	}
-	return nil
+	return body, nil
 }
 
 // Login to the Mediawiki Website.
+//
+// This uses action=login, which current versions of MediaWiki only accept
+// for bot passwords created at Special:BotPasswords. Use ClientLogin to log
+// in with the main account password.
 func (m *MWApi) Login(username, password string) error {
+	return m.LoginContext(context.Background(), username, password)
+}
+
+// LoginContext is like Login but uses ctx for the login requests.
+func (m *MWApi) LoginContext(ctx context.Context, username, password string) error {
 	if username == "" {
 		return errors.New("empty username supplied")
 	}
 	if password == "" {
 		return errors.New("empty password supplied")
 	}
+	m.mu.Lock()
 	m.username = username
 	m.password = password
+	m.clientLogin = false
+	m.prompt = nil
+	m.mu.Unlock()
 
 	query := map[string]string{
 		"action":     "login",
-		"lgname":     m.username,
-		"lgpassword": m.password,
+		"lgname":     username,
+		"lgpassword": password,
 	}
 
 	if m.Domain != "" {
 		query["lgdomain"] = m.Domain
 	}
 
-	body, err := m.API(query)
+	// Wikis older than 1.27 can't hand out login tokens through meta=tokens,
+	// instead they respond with NeedToken and the token to use.
+	if token, err := m.TokenContext(ctx, LoginToken); err == nil {
+		query["lgtoken"] = token
+	}
+
+	body, err := m.APIContext(ctx, query)
 	if err != nil {
 		return err
 	}
@@ -366,15 +435,17 @@ func (m *MWApi) Login(username, password string) error {
 	}
 
 	if response.Login.Result == "Success" {
+		m.setUser(response.Login.LgUsername, username)
+		m.clearTokens()
 		return nil
 	} else if response.Login.Result != "NeedToken" {
-		return errors.New("Error logging in: " + response.Login.Result)
+		return &APIError{Code: response.Login.Result, Info: response.Login.Reason, Module: "login"}
 	}
 
 	// Need to use the login token
 	query["lgtoken"] = response.Login.Token
 
-	body, err = m.API(query)
+	body, err = m.APIContext(ctx, query)
 	if err != nil {
 		return err
 	}
@@ -385,52 +456,53 @@ func (m *MWApi) Login(username, password string) error {
 	}
 
 	if response.Login.Result != "Success" {
-		return errors.New("Error logging in: " + response.Login.Result)
+		return &APIError{Code: response.Login.Result, Info: response.Login.Reason, Module: "login"}
 	}
+	m.setUser(response.Login.LgUsername, username)
+	m.clearTokens()
 	return nil
 }
 
-// GetEditToken retrieves an edit token from the MediaWiki site and saves it.
+// GetEditToken retrieves a fresh edit (csrf) token from the MediaWiki site
+// and saves it.
 //
 // This is necessary for editing any page.
 //
 // The Edit() function will call this automatically
 // but it is available if you want to make direct
-// calls to API().
+// calls to API(). The token can then be retrieved with Token(CSRFToken).
 func (m *MWApi) GetEditToken() error {
-	query := map[string]string{
-		"action":  "query",
-		"prop":    "info|revisions",
-		"intoken": "edit",
-		"titles":  "Main Page",
-	}
+	return m.GetEditTokenContext(context.Background())
+}
 
-	body, err := m.API(query)
-	if err != nil {
-		return err
-	}
-	var response Response
-	err = json.Unmarshal(body, &response)
-	if err != nil {
-		return err
-	}
-	pl := response.PageSlice()
-	if len(pl) < 1 {
-		return errors.New("no pages returned for edittoken query")
-	}
-	m.edittoken = pl[0].Edittoken
-	return nil
+// GetEditTokenContext is like GetEditToken but uses ctx for the request.
+func (m *MWApi) GetEditTokenContext(ctx context.Context) error {
+	m.InvalidateToken(CSRFToken)
+	_, err := m.TokenContext(ctx, CSRFToken)
+	return err
 }
 
 // Logout of the MediaWiki website
+//
+// The stored credentials are forgotten as well, so AutoRelogin won't log
+// in again afterwards.
 func (m *MWApi) Logout() {
-	m.API(map[string]string{"action": "logout"})
+	m.LogoutContext(context.Background())
+}
+
+// LogoutContext is like Logout but uses ctx for the request.
+func (m *MWApi) LogoutContext(ctx context.Context) {
+	m.withToken(ctx, CSRFToken, func(token string) ([]byte, error) {
+		return m.APIContext(ctx, map[string]string{"action": "logout", "token": token})
+	})
+	m.forgetLogin()
 }
 
 // Edit a page.
 //
 // This function will request an edit token if the MWApi struct doesn't already
-// contain one.
+// contain one, and will request a new one and try again if the server
+// rejects the cached token.
 //
 // Example:
 //
@@ -440,37 +512,26 @@ func (m *MWApi) Logout() {
 //      "text":    "THE ENTIRE TEXT OF THE PAGE",
 //  }
 //  err = client.Edit(editConfig)
+//
+// EditPage offers typed options and returns the result of the edit.
 func (m *MWApi) Edit(values map[string]string) error {
-	if m.edittoken == "" {
-		err := m.GetEditToken()
-		if err != nil {
-			return err
-		}
-	}
-	query := map[string]string{
-		"action": "edit",
-		"token":  m.edittoken,
-	}
-	body, err := m.API(query, values)
-	if err != nil {
-		return err
-	}
-
-	var response outerEdit
-	err = json.Unmarshal(body, &response)
-	if err != nil {
-		return err
-	}
+	return m.EditContext(context.Background(), values)
+}
 
-	if response.Edit.Result != "Success" {
-		return errors.New(response.Edit.Result)
-	}
-	return nil
+// EditContext is like Edit but uses ctx for the token lookup and the edit.
+func (m *MWApi) EditContext(ctx context.Context, values map[string]string) error {
+	_, err := m.edit(ctx, values)
+	return err
 }
 
 // Read returns the most recent revision of a Page. If an error occurs, nil is
 // returned.
 func (m *MWApi) Read(pageName string) (*Page, error) {
+	return m.ReadContext(context.Background(), pageName)
+}
+
+// ReadContext is like Read but uses ctx for the request.
+func (m *MWApi) ReadContext(ctx context.Context, pageName string) (*Page, error) {
 	query := map[string]string{
 		"action":  "query",
 		"prop":    "revisions",
@@ -478,7 +539,10 @@ func (m *MWApi) Read(pageName string) (*Page, error) {
 		"rvlimit": "1",
 		"rvprop":  "content|timestamp|user|comment",
 	}
-	body, err := m.API(query)
+	if m.FollowRedirects {
+		query["redirects"] = "1"
+	}
+	body, err := m.APIContext(ctx, query)
 	if err != nil {
 		return nil, err
 	}
@@ -506,6 +570,12 @@ func (m *MWApi) Read(pageName string) (*Page, error) {
 //
 // This is used by all internal functions to interact with the API.
 func (m *MWApi) API(values ...map[string]string) ([]byte, error) {
+	return m.APIContext(context.Background(), values...)
+}
+
+// APIContext is like API but uses ctx for the request. The request is
+// abandoned if ctx is cancelled or its deadline passes.
+func (m *MWApi) APIContext(ctx context.Context, values ...map[string]string) ([]byte, error) {
 	query := m.url.Query()
 	for _, valuemap := range values {
 		for key, value := range valuemap {
@@ -513,7 +583,7 @@ func (m *MWApi) API(values ...map[string]string) ([]byte, error) {
 		}
 	}
 	query.Set("format", m.format)
-	body, err := m.postForm(query)
+	body, err := m.postForm(ctx, query)
 	if err != nil {
 		return nil, err
 	}
@@ -1,6 +1,8 @@
 package mediawiki
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"net/http"
@@ -8,10 +10,11 @@ import (
 	"net/url"
 	"strings"
 	"testing"
+	"time"
 )
 
 const (
-	editTokenReponse = `{"query":{"pages":{"15580374":{"pageid":15580374,"ns":0,"title":"Main Page","contentmodel":"wikitext","pagelanguage":"en","touched":"2013-11-02T14:08:05Z","lastrevid":574690625,"counter":"","length":6391,"starttimestamp":"2013-11-02T15:24:43Z","edittoken":"+\\","revisions":[{"revid":574690625,"parentid":574690493,"minor":"","user":"Tariqabjotu","timestamp":"2013-09-27T03:10:17Z","comment":"removing unnecessary pipe"}]}}}}`
+	editTokenReponse = `{"batchcomplete":"","query":{"tokens":{"csrftoken":"+\\"}}}`
 	firstLogin       = `{"login":{"result":"NeedToken","token":"8f48670ddc7fa9d5fa7e7fa2ae147e80","cookieprefix":"wikidb","sessionid":"927e0d298f6f3b5bb21228803fd9c0eb"}}`
 	secondLogin      = `{"login":{"result":"Success","token":"8f48670ddc7fa9d5fa7e7fa2ae147e80","cookieprefix":"wikidb","sessionid":"927e0d298f6f3b5bb21228803fd9c0eb"}}`
 	failedLogin      = `{"login":{"result":"ERROR THING","token":"8f48670ddc7fa9d5fa7e7fa2ae147e80","cookieprefix":"wikidb","sessionid":"927e0d298f6f3b5bb21228803fd9c0eb"}}`
@@ -53,10 +56,10 @@ func TestGetEditToken(t *testing.T) {
 	} else {
 		t.Log("Got edit token")
 	}
-	if test.client.edittoken == `+\` {
+	if test.client.tokens[CSRFToken] == `+\` {
 		t.Log("Edit token correct")
 	} else {
-		t.Errorf("Incorrect edit token: %s", test.client.edittoken)
+		t.Errorf("Incorrect edit token: %s", test.client.tokens[CSRFToken])
 	}
 }
 
@@ -152,7 +155,7 @@ func TestPostForm(t *testing.T) {
 	if err != nil {
 		t.Fatalf("Error creating client: %s", err.Error())
 	}
-	value, err := client.postForm(url.Values{"KEY": []string{"VALUE"}})
+	value, err := client.postForm(context.Background(), url.Values{"KEY": []string{"VALUE"}})
 	if err != nil {
 		t.Errorf("Error posting data: %s", err.Error())
 	}
@@ -255,10 +258,10 @@ func TestDownloadNoFiles(t *testing.T) {
 		t.Fatalf("Error creating client: %s", err)
 	}
 	_, err = client.Download(ts.URL)
-	if err != nil {
+	if errors.Is(err, ErrMissingTitle) {
 		t.Log("Successfully returned error when no files were found", err)
 	} else {
-		t.Fatal("No error return when no files were found", err)
+		t.Fatal("No missingtitle error returned when no files were found", err)
 	}
 }
 
@@ -306,7 +309,7 @@ func TestUpload(t *testing.T) {
 	if err != nil {
 		t.Fatalf("Error creating client: %s", err.Error())
 	}
-	client.edittoken = "ASDFASDF"
+	client.tokens[CSRFToken] = "ASDFASDF"
 	err = client.Upload("test.txt", strings.NewReader("THIS IS A TEST"))
 	if err != nil {
 		t.Fatalf("Error trying to upload file: %s", err)
@@ -317,7 +320,7 @@ func TestUploadAutoToken(t *testing.T) {
 	test := BuildUp(editTokenReponse, t)
 	defer test.TearDown()
 	test.client.Upload("stuff", strings.NewReader("stuff"))
-	if test.client.edittoken == "" {
+	if test.client.tokens[CSRFToken] == "" {
 		t.Fatalf("Edit token did not get set properly")
 	}
 }
@@ -325,7 +328,7 @@ func TestUploadAutoToken(t *testing.T) {
 func TestUploadFailResponse(t *testing.T) {
 	test := BuildUp(`{"upload":{"result":"THIS SHOULD BE AN ERROR"}}`, t)
 	defer test.TearDown()
-	test.client.edittoken = "ASDF"
+	test.client.tokens[CSRFToken] = "ASDF"
 	err := test.client.Upload("stuff", strings.NewReader("stuff"))
 	if err == nil {
 		t.Fatal("Did not generate error when upload returned failed result", err)
@@ -349,7 +352,7 @@ func TestEditAutoToken(t *testing.T) {
 	test := BuildUp(editTokenReponse, t)
 	defer test.TearDown()
 	test.client.Edit(map[string]string{"thing": "OTHER THING"})
-	if test.client.edittoken == "" {
+	if test.client.tokens[CSRFToken] == "" {
 		t.Fatalf("Edit token did not get set properly")
 	}
 }
@@ -357,7 +360,7 @@ func TestEditAutoToken(t *testing.T) {
 func TestEditSuccess(t *testing.T) {
 	test := BuildUp(editsuccess, t)
 	defer test.TearDown()
-	test.client.edittoken = "asdf"
+	test.client.tokens[CSRFToken] = "asdf"
 	err := test.client.Edit(map[string]string{"title": "somepage"})
 	if err != nil {
 		t.Fatal("Did not get non-error response back", err)
@@ -385,9 +388,7 @@ func TestBasicAuth(t *testing.T) {
 		t.Fatalf("Error creating client: %s", err.Error())
 	}
 
-	client.UseBasicAuth = true
-	client.BasicAuthUser = "foo"
-	client.BasicAuthPass = "bar"
+	client.Auth = &BasicAuth{Username: "foo", Password: "bar"}
 
 	t.Log(client)
 
@@ -396,3 +397,46 @@ func TestBasicAuth(t *testing.T) {
 		t.Fatalf("API() returned a non-nil error: %s", err.Error())
 	}
 }
+
+func TestAPIContextCancel(t *testing.T) {
+	done := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-done
+	}))
+	defer ts.Close()
+	defer close(done)
+	client, err := New(ts.URL, "TESTING")
+	if err != nil {
+		t.Fatalf("Error creating client: %s", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = client.APIContext(ctx, map[string]string{"action": "query"})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected deadline exceeded error, got: %v", err)
+	}
+}
+
+func TestDownloadContextCancel(t *testing.T) {
+	done := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "POST" {
+			r.ParseForm()
+			fmt.Fprintln(w, fmt.Sprintf(fileURL, r.Form.Get("titles")))
+			return
+		}
+		<-done
+	}))
+	defer ts.Close()
+	defer close(done)
+	client, err := New(ts.URL, "TESTING")
+	if err != nil {
+		t.Fatalf("Error creating client: %s", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = client.DownloadContext(ctx, ts.URL)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected deadline exceeded error, got: %v", err)
+	}
+}
@@ -0,0 +1,161 @@
+//
+// Copyright 2016 James McGuire
+//
+// This code is covered under the MIT License
+// Please refer to the LICENSE file in the root of this
+// repository for any information.
+
+package mediawiki
+
+import (
+	"context"
+	"encoding/json"
+)
+
+// QueryIterator walks through every batch of a list, prop or generator
+// query by following the "continue" block returned with each response.
+//
+// Example:
+//
+//	iter := client.Query(map[string]string{
+//	    "list":    "allpages",
+//	    "aplimit": "max",
+//	})
+//	for iter.Next() {
+//	    response, err := iter.Response()
+//	    if err != nil {
+//	        // Handle the error
+//	    }
+//	    // Do something with response
+//	}
+//	if err := iter.Err(); err != nil {
+//	    // Handle the error
+//	}
+type QueryIterator struct {
+	m      *MWApi
+	ctx    context.Context
+	values []map[string]string
+	cont   map[string]string
+	body   []byte
+	err    error
+	done   bool
+}
+
+// The continue block is a flat object, but the values may be returned as
+// either strings or numbers depending on the module.
+type continueResponse struct {
+	Continue map[string]json.RawMessage
+}
+
+// Query returns a QueryIterator for an action=query request built from
+// values. The first batch is not requested until Next is called.
+func (m *MWApi) Query(values ...map[string]string) *QueryIterator {
+	return m.QueryContext(context.Background(), values...)
+}
+
+// QueryContext is like Query but uses ctx for every batch requested by the
+// iterator.
+func (m *MWApi) QueryContext(ctx context.Context, values ...map[string]string) *QueryIterator {
+	return &QueryIterator{
+		m:      m,
+		ctx:    ctx,
+		values: values,
+		cont:   map[string]string{"continue": ""},
+	}
+}
+
+// Next requests the next batch of results. It returns false once the
+// results are exhausted or an error occurs, which can be checked with Err.
+func (q *QueryIterator) Next() bool {
+	if q.done || q.err != nil {
+		return false
+	}
+
+	values := []map[string]string{{"action": "query"}}
+	values = append(values, q.values...)
+	values = append(values, q.cont)
+	body, err := q.m.APIContext(q.ctx, values...)
+	if err != nil {
+		q.err = err
+		q.body = nil
+		return false
+	}
+	q.body = body
+
+	var response continueResponse
+	err = json.Unmarshal(body, &response)
+	if err != nil {
+		q.err = err
+		return false
+	}
+	if len(response.Continue) == 0 {
+		q.done = true
+		return true
+	}
+
+	cont := make(map[string]string, len(response.Continue))
+	for key, raw := range response.Continue {
+		var value string
+		if err := json.Unmarshal(raw, &value); err != nil {
+			// Numeric continuation values are passed back verbatim.
+			value = string(raw)
+		}
+		cont[key] = value
+	}
+	q.cont = cont
+	return true
+}
+
+// Body returns the raw JSON of the current batch.
+func (q *QueryIterator) Body() []byte {
+	return q.body
+}
+
+// Response unmarshals the current batch in to a Response.
+func (q *QueryIterator) Response() (*Response, error) {
+	var response Response
+	err := json.Unmarshal(q.body, &response)
+	if err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// Err returns the first error encountered while iterating, if any.
+func (q *QueryIterator) Err() error {
+	return q.err
+}
+
+// Pages is a convenience function that runs the query to completion and
+// returns every page from every batch. Properties split across batches
+// (for example revisions or imageinfo) are merged in to a single Page.
+func (q *QueryIterator) Pages() ([]Page, error) {
+	var order []string
+	pages := map[string]*Page{}
+	for q.Next() {
+		response, err := q.Response()
+		if err != nil {
+			return nil, err
+		}
+		for key, page := range response.Query.Pages {
+			existing, ok := pages[key]
+			if !ok {
+				page := page
+				pages[key] = &page
+				order = append(order, key)
+				continue
+			}
+			existing.Revisions = append(existing.Revisions, page.Revisions...)
+			existing.Imageinfo = append(existing.Imageinfo, page.Imageinfo...)
+		}
+	}
+	if err := q.Err(); err != nil {
+		return nil, err
+	}
+
+	pl := make([]Page, 0, len(order))
+	for _, key := range order {
+		pl = append(pl, *pages[key])
+	}
+	return pl, nil
+}
@@ -0,0 +1,82 @@
+package mediawiki
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+const (
+	queryFirstBatch  = `{"continue":{"apcontinue":"Bar","continue":"-||"},"query":{"pages":{"1":{"pageid":1,"ns":0,"title":"Alpha"}}}}`
+	querySecondBatch = `{"continue":{"rvcontinue":20150101,"continue":"||"},"query":{"pages":{"2":{"pageid":2,"ns":0,"title":"Bar","revisions":[{"revid":1}]}}}}`
+	queryLastBatch   = `{"batchcomplete":"","query":{"pages":{"2":{"pageid":2,"ns":0,"title":"Bar","revisions":[{"revid":2}]}}}}`
+)
+
+func TestQueryContinue(t *testing.T) {
+	requests := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		err := r.ParseForm()
+		if err != nil {
+			panic(err)
+		}
+		requests++
+		if r.Form.Get("action") != "query" || r.Form.Get("list") != "allpages" {
+			t.Errorf("Original query values were not sent: %v", r.Form)
+		}
+		switch {
+		case r.Form.Get("rvcontinue") == "20150101":
+			if r.Form.Get("continue") != "||" {
+				t.Errorf("Wrong continue value: %s", r.Form.Get("continue"))
+			}
+			fmt.Fprintln(w, queryLastBatch)
+		case r.Form.Get("apcontinue") == "Bar":
+			if r.Form.Get("continue") != "-||" {
+				t.Errorf("Wrong continue value: %s", r.Form.Get("continue"))
+			}
+			fmt.Fprintln(w, querySecondBatch)
+		default:
+			if _, ok := r.Form["continue"]; !ok {
+				t.Error("First request did not ask for new-style continuation")
+			}
+			fmt.Fprintln(w, queryFirstBatch)
+		}
+	}))
+	defer ts.Close()
+	client, err := New(ts.URL, "TESTING")
+	if err != nil {
+		t.Fatalf("Error creating client: %s", err)
+	}
+
+	pages, err := client.Query(map[string]string{"list": "allpages"}).Pages()
+	if err != nil {
+		t.Fatalf("Error iterating query: %s", err)
+	}
+	if requests != 3 {
+		t.Errorf("Expected 3 requests, got %d", requests)
+	}
+	if len(pages) != 2 {
+		t.Fatalf("Expected 2 pages, got %d", len(pages))
+	}
+	if pages[0].Title != "Alpha" || pages[1].Title != "Bar" {
+		t.Errorf("Pages returned in the wrong order: %v", pages)
+	}
+	if len(pages[1].Revisions) != 2 {
+		t.Errorf("Revisions were not merged across batches: %v", pages[1].Revisions)
+	}
+}
+
+func TestQueryError(t *testing.T) {
+	test := BuildUp(mwerror, t)
+	defer test.TearDown()
+	iter := test.client.Query(map[string]string{"list": "allpages"})
+	if iter.Next() {
+		t.Fatal("Next returned true for an error response")
+	}
+	if iter.Err() == nil {
+		t.Fatal("Mediawiki error was not returned from the iterator")
+	}
+	if iter.Next() {
+		t.Fatal("Next returned true after an error")
+	}
+}
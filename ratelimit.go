@@ -0,0 +1,102 @@
+//
+// Copyright 2016 James McGuire
+//
+// This code is covered under the MIT License
+// Please refer to the LICENSE file in the root of this
+// repository for any information.
+
+package mediawiki
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Limiter throttles requests sent to the API. Wait blocks until a request
+// may be sent or ctx is done.
+//
+// RateLimiter is a simple implementation, but *rate.Limiter from
+// golang.org/x/time/rate can be used as well.
+type Limiter interface {
+	Wait(ctx context.Context) error
+}
+
+// RateLimiter allows one request every interval, with up to burst requests
+// sent back to back after a quiet period. It is safe for concurrent use.
+//
+// Example, allowing 10 reads a second but only one edit every 5 seconds:
+//
+//	client.ReadLimiter = mediawiki.NewRateLimiter(100*time.Millisecond, 10)
+//	client.WriteLimiter = mediawiki.NewRateLimiter(5*time.Second, 1)
+type RateLimiter struct {
+	mu       sync.Mutex
+	interval time.Duration
+	burst    int
+	// The earliest time at which the next request may be sent.
+	next time.Time
+}
+
+// NewRateLimiter returns a RateLimiter allowing one request every interval.
+// Values of burst below 1 are treated as 1.
+func NewRateLimiter(interval time.Duration, burst int) *RateLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+	return &RateLimiter{interval: interval, burst: burst}
+}
+
+// Wait blocks until the next request may be sent or ctx is done.
+func (l *RateLimiter) Wait(ctx context.Context) error {
+	l.mu.Lock()
+	now := time.Now()
+	earliest := now.Add(-time.Duration(l.burst-1) * l.interval)
+	if l.next.Before(earliest) {
+		l.next = earliest
+	}
+	wait := l.next.Sub(now)
+	l.next = l.next.Add(l.interval)
+	l.mu.Unlock()
+
+	if wait <= 0 {
+		return nil
+	}
+	return sleep(ctx, wait)
+}
+
+// API modules that modify the wiki and are throttled by WriteLimiter.
+var writeModules = map[string]bool{
+	"block":              true,
+	"changecontentmodel": true,
+	"delete":             true,
+	"edit":               true,
+	"emailuser":          true,
+	"filerevert":         true,
+	"import":             true,
+	"managetags":         true,
+	"mergehistory":       true,
+	"move":               true,
+	"options":            true,
+	"patrol":             true,
+	"protect":            true,
+	"rollback":           true,
+	"setpagelanguage":    true,
+	"tag":                true,
+	"unblock":            true,
+	"undelete":           true,
+	"upload":             true,
+	"userrights":         true,
+	"watch":              true,
+}
+
+// Wait for the limiter that applies to requests for module, if one is set.
+func (m *MWApi) throttle(ctx context.Context, module string) error {
+	limiter := m.ReadLimiter
+	if writeModules[module] {
+		limiter = m.WriteLimiter
+	}
+	if limiter == nil {
+		return nil
+	}
+	return limiter.Wait(ctx)
+}
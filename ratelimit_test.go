@@ -0,0 +1,86 @@
+package mediawiki
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type countingLimiter struct {
+	calls int
+}
+
+func (l *countingLimiter) Wait(ctx context.Context) error {
+	l.calls++
+	return nil
+}
+
+func TestRateLimiterBurst(t *testing.T) {
+	limiter := NewRateLimiter(50*time.Millisecond, 3)
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		if err := limiter.Wait(context.Background()); err != nil {
+			t.Fatalf("Wait returned an error: %s", err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed > 40*time.Millisecond {
+		t.Errorf("Burst requests were delayed: %s", elapsed)
+	}
+	if err := limiter.Wait(context.Background()); err != nil {
+		t.Fatalf("Wait returned an error: %s", err)
+	}
+	if elapsed := time.Since(start); elapsed < 40*time.Millisecond {
+		t.Errorf("Request after the burst was not delayed: %s", elapsed)
+	}
+}
+
+func TestRateLimiterContext(t *testing.T) {
+	limiter := NewRateLimiter(time.Hour, 1)
+	limiter.Wait(context.Background())
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := limiter.Wait(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("Expected deadline exceeded, got: %v", err)
+	}
+}
+
+func TestReadWriteLimiters(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/") {
+			fmt.Fprintln(w, `{"upload":{"result":"Success"}}`)
+			return
+		}
+		r.ParseForm()
+		if r.Form.Get("meta") == "tokens" {
+			fmt.Fprintln(w, editTokenReponse)
+			return
+		}
+		fmt.Fprintln(w, editsuccess)
+	}))
+	defer ts.Close()
+	client, err := New(ts.URL, "TESTING")
+	if err != nil {
+		t.Fatalf("Error creating client: %s", err)
+	}
+	reads := &countingLimiter{}
+	writes := &countingLimiter{}
+	client.ReadLimiter = reads
+	client.WriteLimiter = writes
+
+	err = client.Edit(map[string]string{"title": "somepage"})
+	if err != nil {
+		t.Fatalf("Error editing: %s", err)
+	}
+	err = client.Upload("stuff", strings.NewReader("stuff"))
+	if err != nil {
+		t.Fatalf("Error uploading: %s", err)
+	}
+	// One read for the token, one write each for the edit and the upload.
+	if reads.calls != 1 || writes.calls != 2 {
+		t.Errorf("Wrong limiter used: %d reads, %d writes", reads.calls, writes.calls)
+	}
+}
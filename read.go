@@ -0,0 +1,162 @@
+//
+// Copyright 2016 James McGuire
+//
+// This code is covered under the MIT License
+// Please refer to the LICENSE file in the root of this
+// repository for any information.
+
+package mediawiki
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"strings"
+)
+
+// The number of titles ReadMany sends per request, and the number it
+// sends for users with the apihighlimits right.
+const (
+	readBatchSize     = 50
+	readHighBatchSize = 500
+)
+
+// ReadResult is the result of reading one of the titles passed to ReadMany.
+// Err is set if the page couldn't be read, for example because it doesn't
+// exist.
+type ReadResult struct {
+	Page *Page
+	Err  error
+	// Fragment is the section of Page that a followed redirect pointed
+	// to, see MWApi.FollowRedirects.
+	Fragment string
+}
+
+// ReadRevision returns the page with the revision revid as its only
+// revision. If there is no such revision the error satisfies
+// errors.Is(err, ErrNoSuchRevID).
+func (m *MWApi) ReadRevision(revid int) (*Page, error) {
+	return m.ReadRevisionContext(context.Background(), revid)
+}
+
+// ReadRevisionContext is like ReadRevision but uses ctx for the request.
+func (m *MWApi) ReadRevisionContext(ctx context.Context, revid int) (*Page, error) {
+	query := map[string]string{
+		"action": "query",
+		"prop":   "revisions",
+		"revids": strconv.Itoa(revid),
+		"rvprop": "ids|content|timestamp|user|comment",
+	}
+	body, err := m.APIContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	var response Response
+	err = json.Unmarshal(body, &response)
+	if err != nil {
+		return nil, err
+	}
+	pl := response.PageSlice()
+	if len(pl) != 1 || len(pl[0].Revisions) != 1 {
+		return nil, &APIError{Code: ErrNoSuchRevID.Code, Info: "There is no revision with ID " + strconv.Itoa(revid), Module: "query"}
+	}
+	return &pl[0], nil
+}
+
+// ReadMany returns the most recent revision of every page in titles, keyed
+// by the titles as they were passed in. Pages that can't be read, because
+// they are missing or the title is invalid, have an error in their
+// ReadResult that satisfies errors.Is(err, ErrMissingTitle) or
+// errors.Is(err, ErrInvalidTitle) instead of failing the whole call.
+//
+// The titles are requested 50 at a time, or 500 at a time for users with
+// the apihighlimits right.
+func (m *MWApi) ReadMany(titles ...string) (map[string]*ReadResult, error) {
+	return m.ReadManyContext(context.Background(), titles...)
+}
+
+// ReadManyContext is like ReadMany but uses ctx for every request.
+func (m *MWApi) ReadManyContext(ctx context.Context, titles ...string) (map[string]*ReadResult, error) {
+	size := readBatchSize
+	if len(titles) > size {
+		info, err := m.UserInfoContext(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if info.HasRight("apihighlimits") {
+			size = readHighBatchSize
+		}
+	}
+
+	results := make(map[string]*ReadResult, len(titles))
+	for len(titles) > 0 {
+		n := size
+		if len(titles) < n {
+			n = len(titles)
+		}
+		err := m.readBatch(ctx, titles[:n], results)
+		if err != nil {
+			return nil, err
+		}
+		titles = titles[n:]
+	}
+	return results, nil
+}
+
+// Read a batch of titles and add them to results.
+func (m *MWApi) readBatch(ctx context.Context, titles []string, results map[string]*ReadResult) error {
+	query := map[string]string{
+		"prop":   "revisions",
+		"titles": strings.Join(titles, "|"),
+		"rvprop": "content|timestamp|user|comment",
+	}
+	if m.FollowRedirects {
+		query["redirects"] = "1"
+	}
+
+	// Large pages are split across batches, which are merged by title.
+	pages := map[string]*Page{}
+	// Collects the title changes from every batch.
+	var mapping Response
+	iter := m.QueryContext(ctx, query)
+	for iter.Next() {
+		response, err := iter.Response()
+		if err != nil {
+			return err
+		}
+		mapping.Query.Normalized = append(mapping.Query.Normalized, response.Query.Normalized...)
+		mapping.Query.Converted = append(mapping.Query.Converted, response.Query.Converted...)
+		mapping.Query.Redirects = append(mapping.Query.Redirects, response.Query.Redirects...)
+		for _, page := range response.Query.Pages {
+			existing, ok := pages[page.Title]
+			if !ok {
+				page := page
+				pages[page.Title] = &page
+				continue
+			}
+			existing.Revisions = append(existing.Revisions, page.Revisions...)
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return err
+	}
+
+	for _, title := range titles {
+		resolved, fragment := mapping.Resolve(title)
+		result := &ReadResult{Fragment: fragment}
+		page, ok := pages[resolved]
+		switch {
+		case !ok:
+			result.Err = &APIError{Code: ErrMissingTitle.Code, Info: "No page was returned for " + title, Module: "query"}
+		case bool(page.Invalid):
+			result.Err = &APIError{Code: ErrInvalidTitle.Code, Info: page.InvalidReason, Module: "query"}
+		case bool(page.Missing):
+			result.Err = &APIError{Code: ErrMissingTitle.Code, Info: "The page " + page.Title + " doesn't exist", Module: "query"}
+		default:
+			result.Page = page
+		}
+		results[title] = result
+	}
+	return nil
+}
@@ -0,0 +1,192 @@
+package mediawiki
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+const readMany = `{"batchcomplete":"","query":{"normalized":[{"from":"main page","to":"Main Page"}],"pages":{"-2":{"ns":0,"title":"Missing page","missing":""},"-1":{"title":"Bad<title>","invalidreason":"The requested page title contains invalid characters: \"<\".","invalid":""},"1":{"pageid":1,"ns":0,"title":"Main Page","revisions":[{"user":"Alice","timestamp":"2016-05-01T10:00:00Z","comment":"","*":"MAIN PAGE TEXT"}]}}}}`
+
+func TestReadMany(t *testing.T) {
+	test := BuildUp(readMany, t)
+	defer test.TearDown()
+
+	results, err := test.client.ReadMany("main page", "Missing page", "Bad<title>")
+	if err != nil {
+		t.Fatalf("Error reading pages: %s", err)
+	}
+	if len(results) != 3 {
+		t.Fatalf("Expected 3 results, got %d", len(results))
+	}
+	main := results["main page"]
+	if main.Err != nil || main.Page.Title != "Main Page" || main.Page.Revisions[0].Body != "MAIN PAGE TEXT" {
+		t.Errorf("Wrong result for main page: %+v", main)
+	}
+	if !errors.Is(results["Missing page"].Err, ErrMissingTitle) {
+		t.Errorf("Expected a missing title error, got %v", results["Missing page"].Err)
+	}
+	if !errors.Is(results["Bad<title>"].Err, ErrInvalidTitle) {
+		t.Errorf("Expected an invalid title error, got %v", results["Bad<title>"].Err)
+	}
+}
+
+func TestReadManyBatches(t *testing.T) {
+	var batches []int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		err := r.ParseForm()
+		if err != nil {
+			panic(err)
+		}
+		if r.Form.Get("meta") == "userinfo" {
+			fmt.Fprintln(w, `{"batchcomplete":"","query":{"userinfo":{"id":1,"name":"Alice","rights":["read","edit"]}}}`)
+			return
+		}
+		titles := strings.Split(r.Form.Get("titles"), "|")
+		batches = append(batches, len(titles))
+		pages := make([]string, len(titles))
+		for i, title := range titles {
+			pages[i] = fmt.Sprintf(`"%d":{"pageid":%d,"ns":0,"title":"%s","revisions":[{"*":"TEXT"}]}`, i+1, i+1, title)
+		}
+		fmt.Fprintf(w, `{"batchcomplete":"","query":{"pages":{%s}}}`, strings.Join(pages, ","))
+	}))
+	defer ts.Close()
+	client, err := New(ts.URL, "TESTING")
+	if err != nil {
+		t.Fatalf("Error creating client: %s", err)
+	}
+
+	titles := make([]string, 60)
+	for i := range titles {
+		titles[i] = "Page " + strconv.Itoa(i)
+	}
+	results, err := client.ReadMany(titles...)
+	if err != nil {
+		t.Fatalf("Error reading pages: %s", err)
+	}
+	if len(batches) != 2 || batches[0] != 50 || batches[1] != 10 {
+		t.Errorf("Wrong batches: %v", batches)
+	}
+	for _, title := range titles {
+		if results[title] == nil || results[title].Err != nil {
+			t.Errorf("Wrong result for %s: %+v", title, results[title])
+		}
+	}
+}
+
+func TestReadRevision(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		err := r.ParseForm()
+		if err != nil {
+			panic(err)
+		}
+		if r.Form.Get("revids") == "471" {
+			fmt.Fprintln(w, `{"batchcomplete":"","query":{"pages":{"12":{"pageid":12,"ns":0,"title":"Example","revisions":[{"revid":471,"parentid":465,"user":"Alice","timestamp":"2016-05-01T10:00:00Z","comment":"","*":"OLD TEXT"}]}}}}`)
+			return
+		}
+		fmt.Fprintln(w, `{"batchcomplete":"","query":{"badrevids":{"999":{"revid":999,"missing":""}}}}`)
+	}))
+	defer ts.Close()
+	client, err := New(ts.URL, "TESTING")
+	if err != nil {
+		t.Fatalf("Error creating client: %s", err)
+	}
+
+	page, err := client.ReadRevision(471)
+	if err != nil {
+		t.Fatalf("Error reading revision: %s", err)
+	}
+	if page.Title != "Example" || page.Revisions[0].Revid != 471 || page.Revisions[0].Body != "OLD TEXT" {
+		t.Errorf("Wrong revision: %+v", page)
+	}
+
+	_, err = client.ReadRevision(999)
+	if !errors.Is(err, ErrNoSuchRevID) {
+		t.Errorf("Expected a nosuchrevid error, got %v", err)
+	}
+}
+
+const readRedirects = `{"batchcomplete":"","query":{"normalized":[{"from":"old name","to":"Old name"}],"converted":[{"from":"Colour","to":"Color"}],"redirects":[{"from":"Old name","to":"New name","tofragment":"History"},{"from":"Color","to":"Colors"}],"pages":{"7":{"pageid":7,"ns":0,"title":"New name","revisions":[{"*":"NEW TEXT"}]},"8":{"pageid":8,"ns":0,"title":"Colors","revisions":[{"*":"COLOR TEXT"}]}}}}`
+
+func TestResponseResolve(t *testing.T) {
+	var response Response
+	err := json.Unmarshal([]byte(readRedirects), &response)
+	if err != nil {
+		t.Fatalf("Error unmarshalling response: %s", err)
+	}
+
+	tests := []struct {
+		title, resolved, fragment string
+	}{
+		{"old name", "New name", "History"},
+		{"Colour", "Colors", ""},
+		{"Unrelated", "Unrelated", ""},
+	}
+	for _, test := range tests {
+		resolved, fragment := response.Resolve(test.title)
+		if resolved != test.resolved || fragment != test.fragment {
+			t.Errorf("Resolve(%q) = %q, %q, expected %q, %q", test.title, resolved, fragment, test.resolved, test.fragment)
+		}
+	}
+}
+
+func TestReadManyFollowRedirects(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		err := r.ParseForm()
+		if err != nil {
+			panic(err)
+		}
+		if r.Form.Get("redirects") != "1" {
+			t.Errorf("Redirects were not requested: %v", r.Form)
+		}
+		fmt.Fprintln(w, readRedirects)
+	}))
+	defer ts.Close()
+	client, err := New(ts.URL, "TESTING")
+	if err != nil {
+		t.Fatalf("Error creating client: %s", err)
+	}
+	client.FollowRedirects = true
+
+	results, err := client.ReadMany("old name", "Colour")
+	if err != nil {
+		t.Fatalf("Error reading pages: %s", err)
+	}
+	old := results["old name"]
+	if old.Err != nil || old.Page.Title != "New name" || old.Fragment != "History" {
+		t.Errorf("Wrong result for old name: %+v", old)
+	}
+	colour := results["Colour"]
+	if colour.Err != nil || colour.Page.Revisions[0].Body != "COLOR TEXT" {
+		t.Errorf("Wrong result for Colour: %+v", colour)
+	}
+}
+
+func TestReadFollowRedirects(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		err := r.ParseForm()
+		if err != nil {
+			panic(err)
+		}
+		if r.Form.Get("redirects") != "1" {
+			t.Errorf("Redirects were not requested: %v", r.Form)
+		}
+		fmt.Fprintln(w, readPage)
+	}))
+	defer ts.Close()
+	client, err := New(ts.URL, "TESTING")
+	if err != nil {
+		t.Fatalf("Error creating client: %s", err)
+	}
+	client.FollowRedirects = true
+
+	_, err = client.Read("Some redirect")
+	if err != nil {
+		t.Fatalf("Error reading page: %s", err)
+	}
+}
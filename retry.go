@@ -0,0 +1,120 @@
+//
+// Copyright 2016 James McGuire
+//
+// This code is covered under the MIT License
+// Please refer to the LICENSE file in the root of this
+// repository for any information.
+
+package mediawiki
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RetryPolicy controls how MWApi retries failed requests. It applies to
+// every request sent to the API, including uploads.
+//
+// Requests that fail with maxlag, ratelimited or readonly errors are always
+// retried while attempts remain. See https://www.mediawiki.org/wiki/Manual:Maxlag_parameter
+//
+// Example:
+//
+//	client.Retry = &mediawiki.RetryPolicy{
+//	    MaxAttempts:       5,
+//	    Backoff:           time.Second,
+//	    MaxBackoff:        time.Minute,
+//	    MaxLag:            5,
+//	    HonorRetryAfter:   true,
+//	    RetryServerErrors: true,
+//	}
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts made for a request,
+	// including the first one.
+	MaxAttempts int
+	// Backoff is the delay before the first retry. It doubles after every
+	// attempt. Zero means one second.
+	Backoff time.Duration
+	// MaxBackoff caps the delay between attempts. Zero means no cap.
+	MaxBackoff time.Duration
+	// MaxLag is sent as the maxlag parameter when greater than zero.
+	MaxLag int
+	// HonorRetryAfter waits for the duration given in the Retry-After
+	// header when it is longer than the computed backoff.
+	HonorRetryAfter bool
+	// RetryServerErrors also retries 5xx responses and network errors.
+	RetryServerErrors bool
+}
+
+// Reports whether a request that failed with err should be tried again.
+func (p *RetryPolicy) retryable(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	if errors.Is(err, ErrMaxLag) || errors.Is(err, ErrRateLimited) || errors.Is(err, ErrReadOnly) {
+		return true
+	}
+	if !p.RetryServerErrors {
+		return false
+	}
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.HTTPStatus >= 500
+	}
+	// Anything that isn't an API error came from the transport.
+	return true
+}
+
+// Returns how long to wait before the next attempt. attempt is the number
+// of attempts made so far.
+func (p *RetryPolicy) delay(attempt int, retryAfter string) time.Duration {
+	delay := p.Backoff
+	if delay <= 0 {
+		delay = time.Second
+	}
+	for i := 1; i < attempt; i++ {
+		delay *= 2
+		if p.MaxBackoff > 0 && delay >= p.MaxBackoff {
+			break
+		}
+	}
+	if p.MaxBackoff > 0 && delay > p.MaxBackoff {
+		delay = p.MaxBackoff
+	}
+
+	if p.HonorRetryAfter && retryAfter != "" {
+		var wait time.Duration
+		if seconds, err := strconv.Atoi(retryAfter); err == nil {
+			wait = time.Duration(seconds) * time.Second
+		} else if date, err := http.ParseTime(retryAfter); err == nil {
+			wait = time.Until(date)
+		}
+		if wait > delay {
+			delay = wait
+		}
+	}
+	return delay
+}
+
+// Returns the maxlag parameter to send with every request, if any.
+func (m *MWApi) maxLag() string {
+	if m.Retry == nil || m.Retry.MaxLag <= 0 {
+		return ""
+	}
+	return strconv.Itoa(m.Retry.MaxLag)
+}
+
+// Wait for d or until ctx is done.
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,128 @@
+package mediawiki
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+const maxlagError = `{"error":{"code":"maxlag","info":"Waiting for 10.64.16.27: 7 seconds lagged."}}`
+
+func TestRetryMaxLag(t *testing.T) {
+	requests := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		err := r.ParseForm()
+		if err != nil {
+			panic(err)
+		}
+		requests++
+		if r.Form.Get("maxlag") != "5" {
+			t.Errorf("maxlag was not sent: %v", r.Form)
+		}
+		if requests < 3 {
+			fmt.Fprintln(w, maxlagError)
+			return
+		}
+		fmt.Fprintln(w, `{"status":"PASS"}`)
+	}))
+	defer ts.Close()
+	client, err := New(ts.URL, "TESTING")
+	if err != nil {
+		t.Fatalf("Error creating client: %s", err)
+	}
+	client.Retry = &RetryPolicy{MaxAttempts: 3, Backoff: time.Millisecond, MaxLag: 5}
+
+	_, err = client.API(map[string]string{"action": "query"})
+	if err != nil {
+		t.Fatalf("Request was not retried: %s", err)
+	}
+	if requests != 3 {
+		t.Errorf("Expected 3 requests, got %d", requests)
+	}
+}
+
+func TestRetryGivesUp(t *testing.T) {
+	requests := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		fmt.Fprintln(w, `{"error":{"code":"ratelimited","info":"You've exceeded your rate limit."}}`)
+	}))
+	defer ts.Close()
+	client, err := New(ts.URL, "TESTING")
+	if err != nil {
+		t.Fatalf("Error creating client: %s", err)
+	}
+	client.Retry = &RetryPolicy{MaxAttempts: 2, Backoff: time.Millisecond}
+
+	_, err = client.API(map[string]string{"action": "edit"})
+	if !errors.Is(err, ErrRateLimited) {
+		t.Fatalf("Expected ratelimited error, got: %v", err)
+	}
+	if requests != 2 {
+		t.Errorf("Expected 2 requests, got %d", requests)
+	}
+}
+
+func TestRetryServerErrors(t *testing.T) {
+	requests := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests == 1 {
+			http.Error(w, "Service Unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		err := r.ParseMultipartForm(int64(10000))
+		if err != nil {
+			panic(err)
+		}
+		if r.MultipartForm.Value["maxlag"][0] != "3" {
+			t.Errorf("maxlag was not sent with the upload: %v", r.MultipartForm.Value)
+		}
+		fmt.Fprintln(w, `{"upload":{"result":"Success"}}`)
+	}))
+	defer ts.Close()
+	client, err := New(ts.URL, "TESTING")
+	if err != nil {
+		t.Fatalf("Error creating client: %s", err)
+	}
+	client.tokens[CSRFToken] = "asdf"
+
+	client.Retry = &RetryPolicy{MaxAttempts: 3, Backoff: time.Millisecond, MaxLag: 3}
+	err = client.Upload("stuff", strings.NewReader("stuff"))
+	if err == nil {
+		t.Fatal("5xx response was retried without RetryServerErrors")
+	}
+
+	requests = 0
+	client.Retry.RetryServerErrors = true
+	err = client.Upload("stuff", strings.NewReader("stuff"))
+	if err != nil {
+		t.Fatalf("Upload was not retried after a 5xx response: %s", err)
+	}
+	if requests != 2 {
+		t.Errorf("Expected 2 requests, got %d", requests)
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	policy := &RetryPolicy{Backoff: time.Second, MaxBackoff: 5 * time.Second}
+	for attempt, expected := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second} {
+		if delay := policy.delay(attempt+1, ""); delay != expected {
+			t.Errorf("Attempt %d: expected %s, got %s", attempt+1, expected, delay)
+		}
+	}
+	if delay := policy.delay(1, "30"); delay != time.Second {
+		t.Errorf("Retry-After was used without HonorRetryAfter: %s", delay)
+	}
+	policy.HonorRetryAfter = true
+	if delay := policy.delay(1, "30"); delay != 30*time.Second {
+		t.Errorf("Retry-After was not honored: %s", delay)
+	}
+	if delay := policy.delay(3, "1"); delay != 4*time.Second {
+		t.Errorf("Short Retry-After should not reduce the backoff: %s", delay)
+	}
+}
@@ -0,0 +1,224 @@
+//
+// Copyright 2016 James McGuire
+//
+// This code is covered under the MIT License
+// Please refer to the LICENSE file in the root of this
+// repository for any information.
+
+package mediawiki
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"os"
+)
+
+// ErrSessionInvalid is returned when a restored session is no longer
+// logged in as the user it was saved for.
+var ErrSessionInvalid = errors.New("session is no longer valid")
+
+// Session is the saved state of an MWApi: its cookies, cached tokens and the
+// logged in user. Passwords are never saved.
+//
+// Username is the canonical name of the user as reported by the wiki, which
+// may differ from the name given to Login, for example "Example" for a bot
+// password login as "Example@mybot".
+type Session struct {
+	URL      string            `json:"url"`
+	Username string            `json:"username"`
+	Cookies  []*http.Cookie    `json:"cookies"`
+	Tokens   map[string]string `json:"tokens"`
+}
+
+// UserInfo describes the user the API considers to be logged in.
+type UserInfo struct {
+	ID     int
+	Name   string
+	Groups []string
+	Rights []string
+}
+
+// Anon reports whether the session is not logged in.
+func (u *UserInfo) Anon() bool {
+	return u.ID == 0
+}
+
+// HasRight reports whether the user has the given right, such as
+// apihighlimits.
+func (u *UserInfo) HasRight(right string) bool {
+	for _, r := range u.Rights {
+		if r == right {
+			return true
+		}
+	}
+	return false
+}
+
+// Unmarshal meta=userinfo responses...
+type userInfoResponse struct {
+	Query struct {
+		UserInfo UserInfo
+	}
+}
+
+// UserInfo returns the user that the current session is logged in as,
+// using meta=userinfo.
+func (m *MWApi) UserInfo() (*UserInfo, error) {
+	return m.UserInfoContext(context.Background())
+}
+
+// UserInfoContext is like UserInfo but uses ctx for the request.
+func (m *MWApi) UserInfoContext(ctx context.Context) (*UserInfo, error) {
+	query := map[string]string{
+		"action": "query",
+		"meta":   "userinfo",
+		"uiprop": "groups|rights",
+	}
+	body, err := m.APIContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	var response userInfoResponse
+	err = json.Unmarshal(body, &response)
+	if err != nil {
+		return nil, err
+	}
+	return &response.Query.UserInfo, nil
+}
+
+// SaveSession writes the current session to w as JSON so that it can be
+// restored with LoadSession by a later process.
+//
+// The session contains the login cookies, treat it like a password.
+func (m *MWApi) SaveSession(w io.Writer) error {
+	m.mu.Lock()
+	session := Session{
+		URL:      m.url.String(),
+		Username: m.user,
+		Cookies:  m.client.Jar.Cookies(m.url),
+		Tokens:   make(map[string]string, len(m.tokens)),
+	}
+	for tokenType, token := range m.tokens {
+		session.Tokens[tokenType] = token
+	}
+	m.mu.Unlock()
+
+	return json.NewEncoder(w).Encode(&session)
+}
+
+// SaveSessionFile is like SaveSession but writes the session to a file
+// that only the current user can read.
+func (m *MWApi) SaveSessionFile(path string) error {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	err = m.SaveSession(file)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// LoadSession restores a session saved with SaveSession and checks with
+// meta=userinfo that it is still logged in as the same user. If it isn't,
+// ErrSessionInvalid is returned and the client is left logged out.
+//
+// LoadSession replaces the cookie jar, so it must not be called while other
+// requests are in flight.
+func (m *MWApi) LoadSession(r io.Reader) error {
+	return m.LoadSessionContext(context.Background(), r)
+}
+
+// LoadSessionContext is like LoadSession but uses ctx for the validation
+// request.
+func (m *MWApi) LoadSessionContext(ctx context.Context, r io.Reader) error {
+	var session Session
+	err := json.NewDecoder(r).Decode(&session)
+	if err != nil {
+		return err
+	}
+	if session.URL != m.url.String() {
+		return errors.New("session was saved for " + session.URL)
+	}
+
+	err = m.resetSession()
+	if err != nil {
+		return err
+	}
+	// The jar only hands out names and values, so scope restored cookies
+	// to the whole host.
+	for _, cookie := range session.Cookies {
+		cookie.Path = "/"
+	}
+	m.client.Jar.SetCookies(m.url, session.Cookies)
+
+	m.mu.Lock()
+	m.username = session.Username
+	m.user = session.Username
+	for tokenType, token := range session.Tokens {
+		m.tokens[tokenType] = token
+	}
+	m.mu.Unlock()
+
+	info, err := m.UserInfoContext(ctx)
+	if err == nil && (info.Anon() || (session.Username != "" && info.Name != session.Username)) {
+		err = ErrSessionInvalid
+	}
+	if err != nil {
+		if resetErr := m.resetSession(); resetErr != nil {
+			return resetErr
+		}
+		return err
+	}
+	return nil
+}
+
+// LoadSessionFile is like LoadSession but reads the session from a file.
+func (m *MWApi) LoadSessionFile(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return m.LoadSession(file)
+}
+
+// Record the canonical name of the logged in user, as returned by the
+// login, falling back to the name that was used to log in.
+func (m *MWApi) setUser(name, fallback string) {
+	if name == "" {
+		name = fallback
+	}
+	m.mu.Lock()
+	m.user = name
+	m.mu.Unlock()
+}
+
+// Throw away the cookies, tokens and user of the current session.
+func (m *MWApi) resetSession() error {
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		return err
+	}
+	m.client.Jar = jar
+	m.forgetLogin()
+	return nil
+}
+
+// Throw away the tokens, the stored credentials and the user.
+func (m *MWApi) forgetLogin() {
+	m.mu.Lock()
+	m.username = ""
+	m.user = ""
+	m.password = ""
+	m.clientLogin = false
+	m.prompt = nil
+	m.mu.Unlock()
+	m.clearTokens()
+}
@@ -0,0 +1,148 @@
+package mediawiki
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Returns a server that logs in any user and reports the logged in user
+// from meta=userinfo based on the session cookie.
+func sessionServer(t *testing.T) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		err := r.ParseForm()
+		if err != nil {
+			panic(err)
+		}
+		switch {
+		case r.Form.Get("action") == "login":
+			http.SetCookie(w, &http.Cookie{Name: "wikidb_session", Value: "SESSION", Path: "/"})
+			fmt.Fprintln(w, `{"login":{"result":"Success","lgusername":"Asdf"}}`)
+		case r.Form.Get("meta") == "userinfo":
+			cookie, err := r.Cookie("wikidb_session")
+			if err != nil || cookie.Value != "SESSION" {
+				fmt.Fprintln(w, `{"query":{"userinfo":{"id":0,"name":"127.0.0.1","anon":""}}}`)
+				return
+			}
+			fmt.Fprintln(w, `{"query":{"userinfo":{"id":12,"name":"Asdf","groups":["*","user"],"rights":["read","edit"]}}}`)
+		case r.Form.Get("meta") == "tokens":
+			fmt.Fprintln(w, editTokenReponse)
+		default:
+			t.Errorf("Unexpected request: %v", r.Form)
+		}
+	}))
+}
+
+func TestSessionBotPassword(t *testing.T) {
+	ts := sessionServer(t)
+	defer ts.Close()
+	client, err := New(ts.URL, "TESTING")
+	if err != nil {
+		t.Fatalf("Error creating client: %s", err)
+	}
+	// The wiki reports the canonical name Asdf for this login.
+	err = client.Login("Asdf@mybot", "jkl")
+	if err != nil {
+		t.Fatalf("Error logging in: %s", err)
+	}
+
+	var saved bytes.Buffer
+	err = client.SaveSession(&saved)
+	if err != nil {
+		t.Fatalf("Error saving session: %s", err)
+	}
+	restored, err := New(ts.URL, "TESTING")
+	if err != nil {
+		t.Fatalf("Error creating client: %s", err)
+	}
+	err = restored.LoadSession(&saved)
+	if err != nil {
+		t.Fatalf("Error loading bot password session: %s", err)
+	}
+	if restored.username != "Asdf" {
+		t.Errorf("Wrong username restored: %s", restored.username)
+	}
+}
+
+func TestSessionRoundTrip(t *testing.T) {
+	ts := sessionServer(t)
+	defer ts.Close()
+	client, err := New(ts.URL, "TESTING")
+	if err != nil {
+		t.Fatalf("Error creating client: %s", err)
+	}
+	err = client.Login("Asdf", "jkl")
+	if err != nil {
+		t.Fatalf("Error logging in: %s", err)
+	}
+	err = client.GetEditToken()
+	if err != nil {
+		t.Fatalf("Error getting edit token: %s", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "session.json")
+	err = client.SaveSessionFile(path)
+	if err != nil {
+		t.Fatalf("Error saving session: %s", err)
+	}
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Mode().Perm() != 0600 {
+		t.Errorf("Session file is readable by others: %s", fi.Mode())
+	}
+
+	restored, err := New(ts.URL, "TESTING")
+	if err != nil {
+		t.Fatalf("Error creating client: %s", err)
+	}
+	err = restored.LoadSessionFile(path)
+	if err != nil {
+		t.Fatalf("Error loading session: %s", err)
+	}
+	if restored.username != "Asdf" {
+		t.Errorf("Username was not restored: %s", restored.username)
+	}
+	if restored.tokens[CSRFToken] != `+\` {
+		t.Errorf("Tokens were not restored: %v", restored.tokens)
+	}
+	info, err := restored.UserInfo()
+	if err != nil {
+		t.Fatalf("Error getting user info: %s", err)
+	}
+	if info.Anon() || info.Name != "Asdf" || len(info.Rights) != 2 {
+		t.Errorf("Wrong user info: %#v", info)
+	}
+}
+
+func TestSessionInvalid(t *testing.T) {
+	ts := sessionServer(t)
+	defer ts.Close()
+	client, err := New(ts.URL, "TESTING")
+	if err != nil {
+		t.Fatalf("Error creating client: %s", err)
+	}
+	session := fmt.Sprintf(`{"url":%q,"username":"Asdf","cookies":[{"Name":"wikidb_session","Value":"EXPIRED"}],"tokens":{"csrf":"OLD"}}`, ts.URL)
+	err = client.LoadSession(bytes.NewBufferString(session))
+	if !errors.Is(err, ErrSessionInvalid) {
+		t.Fatalf("Expected ErrSessionInvalid, got: %v", err)
+	}
+	if client.username != "" || len(client.tokens) != 0 {
+		t.Errorf("Invalid session was not discarded: %s %v", client.username, client.tokens)
+	}
+	if len(client.client.Jar.Cookies(client.url)) != 0 {
+		t.Error("Cookies from the invalid session were kept")
+	}
+
+	session = `{"url":"https://other.example.org/w/api.php","cookies":[]}`
+	err = client.LoadSession(bytes.NewBufferString(session))
+	if err == nil {
+		t.Fatal("Loaded a session saved for a different wiki")
+	}
+}
@@ -0,0 +1,142 @@
+//
+// Copyright 2016 James McGuire
+//
+// This code is covered under the MIT License
+// Please refer to the LICENSE file in the root of this
+// repository for any information.
+
+package mediawiki
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// Token types that can be requested with Token.
+//
+// See https://www.mediawiki.org/wiki/API:Tokens
+const (
+	CSRFToken          = "csrf"
+	WatchToken         = "watch"
+	PatrolToken        = "patrol"
+	RollbackToken      = "rollback"
+	UserRightsToken    = "userrights"
+	LoginToken         = "login"
+	CreateAccountToken = "createaccount"
+)
+
+// Unmarshal meta=tokens responses...
+type tokensResponse struct {
+	Query struct {
+		Tokens map[string]string
+	}
+}
+
+// Token returns a token of the requested type, fetching it from the
+// MediaWiki site with meta=tokens if it isn't already cached.
+//
+// Login tokens are never cached as they are only valid for a single login
+// attempt.
+func (m *MWApi) Token(tokenType string) (string, error) {
+	return m.TokenContext(context.Background(), tokenType)
+}
+
+// TokenContext is like Token but uses ctx for the request.
+func (m *MWApi) TokenContext(ctx context.Context, tokenType string) (string, error) {
+	m.mu.Lock()
+	token, ok := m.tokens[tokenType]
+	m.mu.Unlock()
+	if ok {
+		return token, nil
+	}
+
+	query := map[string]string{
+		"action": "query",
+		"meta":   "tokens",
+		"type":   tokenType,
+	}
+	body, err := m.APIContext(ctx, query)
+	if err != nil {
+		return "", err
+	}
+
+	var response tokensResponse
+	err = json.Unmarshal(body, &response)
+	if err != nil {
+		return "", err
+	}
+	token = response.Query.Tokens[tokenType+"token"]
+	if token == "" {
+		return "", errors.New("no " + tokenType + " token returned")
+	}
+
+	if tokenType != LoginToken {
+		m.mu.Lock()
+		m.tokens[tokenType] = token
+		m.mu.Unlock()
+	}
+	return token, nil
+}
+
+// InvalidateToken removes a cached token so that the next call to Token
+// fetches a new one from the server.
+func (m *MWApi) InvalidateToken(tokenType string) {
+	m.mu.Lock()
+	delete(m.tokens, tokenType)
+	m.mu.Unlock()
+}
+
+// Remove every cached token. Tokens are tied to the session so this must
+// be called whenever the session changes.
+func (m *MWApi) clearTokens() {
+	m.mu.Lock()
+	m.tokens = map[string]string{}
+	m.mu.Unlock()
+}
+
+// Remove a cached token only if it is still the one that was rejected, so
+// that a token refreshed by another goroutine isn't thrown away.
+func (m *MWApi) invalidateStaleToken(tokenType, token string) {
+	m.mu.Lock()
+	if m.tokens[tokenType] == token {
+		delete(m.tokens, tokenType)
+	}
+	m.mu.Unlock()
+}
+
+// withToken calls fn with a token of the given type. If the server rejects
+// the token as invalid a fresh one is fetched and fn is called once more.
+//
+// If AutoRelogin is set and fn fails because the session has expired, the
+// client logs in again and calls fn once more with a new token.
+func (m *MWApi) withToken(ctx context.Context, tokenType string, fn func(token string) ([]byte, error)) ([]byte, error) {
+	token, err := m.TokenContext(ctx, tokenType)
+	if err != nil {
+		return nil, err
+	}
+	body, err := fn(token)
+	if errors.Is(err, ErrBadToken) {
+		m.invalidateStaleToken(tokenType, token)
+		token, err = m.TokenContext(ctx, tokenType)
+		if err != nil {
+			return nil, err
+		}
+		body, err = fn(token)
+	}
+	if m.AutoRelogin && (errors.Is(err, ErrAssertUserFailed) || errors.Is(err, ErrAssertBotFailed)) {
+		// Keep the assert error so that callers can still check for it.
+		if loginErr := m.relogin(ctx); loginErr == errNoCredentials {
+			return nil, err
+		} else if loginErr != nil {
+			return nil, fmt.Errorf("%w (logging in again failed: %v)", err, loginErr)
+		}
+		token, err = m.TokenContext(ctx, tokenType)
+		if err != nil {
+			return nil, err
+		}
+		body, err = fn(token)
+	}
+	return body, err
+}
@@ -0,0 +1,130 @@
+package mediawiki
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const badtoken = `{"error":{"code":"badtoken","info":"Invalid CSRF token."}}`
+
+func TestTokenCachedPerType(t *testing.T) {
+	requests := map[string]int{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		err := r.ParseForm()
+		if err != nil {
+			panic(err)
+		}
+		if r.Form.Get("meta") != "tokens" {
+			t.Errorf("Token was not requested with meta=tokens: %v", r.Form)
+		}
+		tokenType := r.Form.Get("type")
+		requests[tokenType]++
+		fmt.Fprintf(w, `{"query":{"tokens":{"%stoken":"%s-%d"}}}`, tokenType, tokenType, requests[tokenType])
+	}))
+	defer ts.Close()
+	client, err := New(ts.URL, "TESTING")
+	if err != nil {
+		t.Fatalf("Error creating client: %s", err)
+	}
+
+	for _, tokenType := range []string{CSRFToken, WatchToken, CSRFToken, WatchToken} {
+		token, err := client.Token(tokenType)
+		if err != nil {
+			t.Fatalf("Error getting %s token: %s", tokenType, err)
+		}
+		if token != tokenType+"-1" {
+			t.Errorf("Wrong %s token: %s", tokenType, token)
+		}
+	}
+	if requests[CSRFToken] != 1 || requests[WatchToken] != 1 {
+		t.Errorf("Tokens were not cached: %v", requests)
+	}
+
+	for i := 0; i < 2; i++ {
+		_, err := client.Token(LoginToken)
+		if err != nil {
+			t.Fatalf("Error getting login token: %s", err)
+		}
+	}
+	if requests[LoginToken] != 2 {
+		t.Errorf("Login token should not be cached: %v", requests)
+	}
+}
+
+func TestEditBadTokenRefresh(t *testing.T) {
+	edits := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		err := r.ParseForm()
+		if err != nil {
+			panic(err)
+		}
+		if r.Form.Get("meta") == "tokens" {
+			fmt.Fprintln(w, `{"query":{"tokens":{"csrftoken":"FRESH"}}}`)
+			return
+		}
+		edits++
+		if r.Form.Get("token") != "FRESH" {
+			fmt.Fprintln(w, badtoken)
+			return
+		}
+		fmt.Fprintln(w, editsuccess)
+	}))
+	defer ts.Close()
+	client, err := New(ts.URL, "TESTING")
+	if err != nil {
+		t.Fatalf("Error creating client: %s", err)
+	}
+	client.tokens[CSRFToken] = "STALE"
+
+	err = client.Edit(map[string]string{"title": "somepage"})
+	if err != nil {
+		t.Fatalf("Edit did not recover from badtoken: %s", err)
+	}
+	if edits != 2 {
+		t.Errorf("Expected 2 edit attempts, got %d", edits)
+	}
+	if client.tokens[CSRFToken] != "FRESH" {
+		t.Errorf("Refreshed token was not cached: %s", client.tokens[CSRFToken])
+	}
+}
+
+func TestUploadBadTokenRefresh(t *testing.T) {
+	uploads := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/") {
+			fmt.Fprintln(w, `{"query":{"tokens":{"csrftoken":"FRESH"}}}`)
+			return
+		}
+		err := r.ParseMultipartForm(int64(10000))
+		if err != nil {
+			panic(err)
+		}
+		uploads++
+		if r.MultipartForm.Value["token"][0] != "FRESH" {
+			fmt.Fprintln(w, badtoken)
+			return
+		}
+		if len(r.MultipartForm.File["file"]) != 1 || r.MultipartForm.File["file"][0].Size != 5 {
+			fmt.Fprintln(w, `{"upload":{"result":"File was not resent"}}`)
+			return
+		}
+		fmt.Fprintln(w, `{"upload":{"result":"Success"}}`)
+	}))
+	defer ts.Close()
+	client, err := New(ts.URL, "TESTING")
+	if err != nil {
+		t.Fatalf("Error creating client: %s", err)
+	}
+	client.tokens[CSRFToken] = "STALE"
+
+	err = client.Upload("stuff", strings.NewReader("stuff"))
+	if err != nil {
+		t.Fatalf("Upload did not recover from badtoken: %s", err)
+	}
+	if uploads != 2 {
+		t.Errorf("Expected 2 upload attempts, got %d", uploads)
+	}
+}
@@ -0,0 +1,728 @@
+//
+// Copyright 2016 James McGuire
+//
+// This code is covered under the MIT License
+// Please refer to the LICENSE file in the root of this
+// repository for any information.
+
+package mediawiki
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The chunk size used by UploadChunked when none is configured.
+const defaultChunkSize = 1 << 20
+
+// How long to wait between checks on an asynchronous upload. The interval
+// doubles after every check.
+const (
+	minPollInterval = 250 * time.Millisecond
+	maxPollInterval = 10 * time.Second
+)
+
+// Codes of common upload warnings.
+//
+// See https://www.mediawiki.org/wiki/API:Upload#Possible_warnings
+const (
+	UploadWarningExists           = "exists"
+	UploadWarningExistsNormalized = "exists-normalized"
+	UploadWarningDuplicate        = "duplicate"
+	UploadWarningDuplicateArchive = "duplicate-archive"
+	UploadWarningWasDeleted       = "was-deleted"
+	UploadWarningBadFilename      = "badfilename"
+	UploadWarningPageExists       = "page-exists"
+	UploadWarningNoChange         = "nochange"
+)
+
+// UploadRequest describes an upload made with UploadFile.
+//
+// See https://www.mediawiki.org/wiki/API:Upload
+type UploadRequest struct {
+	// Filename is the name of the file on the wiki.
+	Filename string
+	// File is the contents of the file. If Chunked is set it is sent in
+	// chunks and Size must be the size of the file. Otherwise Size is
+	// optional, it lets File be sent with a Content-Length when it isn't
+	// an io.Seeker.
+	File    io.Reader
+	Size    int64
+	Chunked *ChunkedUpload
+	// FileKey publishes a file from the stash, such as one that was
+	// refused because of warnings, instead of sending File.
+	FileKey string
+	// URL has the wiki fetch the file from a web server instead of
+	// sending File. See UploadURL.
+	URL string
+	// Async has the wiki fetch URL, or assemble a stashed or chunked
+	// upload, in the background. UploadFile then checks on the upload
+	// until it is done. Not every wiki allows asynchronous uploads, and
+	// fetching URL in the background needs MediaWiki 1.42 or later.
+	Async bool
+
+	// Comment is the upload summary and Text the initial content of the
+	// file description page, Comment is used if it is empty.
+	Comment string
+	Text    string
+	Tags    []string
+	// IgnoreWarnings uploads the file even if the server has warnings,
+	// such as the file already existing.
+	IgnoreWarnings bool
+
+	// Watchlist is one of the Watchlist* values, empty uses the default.
+	Watchlist string
+
+	// OnDuplicate is one of the Duplicate* values. Unless it is
+	// DuplicateUpload, File is hashed first and, if a copy already exists
+	// on the wiki, it isn't uploaded. File must then be an io.Seeker. It
+	// doesn't apply to stashed files or uploads by URL.
+	OnDuplicate string
+}
+
+// UploadWarning is a warning about an upload, such as the file already
+// existing.
+type UploadWarning struct {
+	// Code is the kind of warning, for example one of the UploadWarning*
+	// values.
+	Code string
+	// Files names the files the warning refers to, such as the existing
+	// file or the duplicates.
+	Files []string
+	// Value is the raw value of the warning.
+	Value json.RawMessage
+}
+
+// UploadResult is the result of an upload.
+type UploadResult struct {
+	// Result is Success, or Warning if the upload was refused. With
+	// OnDuplicate it may also be Duplicate, if the file was not uploaded
+	// because of a copy, or Redirect if a redirect to the copy was made.
+	Result   string
+	Filename string
+	// FileKey is the key of the stashed file when the upload was refused
+	// because of warnings. It can be published with UploadRequest.FileKey.
+	FileKey string
+	// ImageInfo describes the uploaded file.
+	ImageInfo *ImageInfo
+	// UploadWarnings lists the warnings about the upload, sorted by code.
+	UploadWarnings []UploadWarning
+	// Duplicates lists the copies found because of OnDuplicate, in which
+	// case Filename is the name of the copy that was used.
+	Duplicates []Duplicate
+	// Warnings returned with the upload.
+	Warnings Warnings
+
+	// The position of the next chunk of a chunked upload.
+	offset int64
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (r *UploadResult) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		Result    string
+		Filename  string
+		FileKey   string
+		ImageInfo *ImageInfo
+		Warnings  map[string]json.RawMessage
+		Offset    int64
+	}
+	err := json.Unmarshal(data, &raw)
+	if err != nil {
+		return err
+	}
+	*r = UploadResult{
+		Result:    raw.Result,
+		Filename:  raw.Filename,
+		FileKey:   raw.FileKey,
+		ImageInfo: raw.ImageInfo,
+		offset:    raw.Offset,
+	}
+
+	codes := make([]string, 0, len(raw.Warnings))
+	for code := range raw.Warnings {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	for _, code := range codes {
+		warning := UploadWarning{Code: code, Value: raw.Warnings[code]}
+		var file string
+		if json.Unmarshal(warning.Value, &file) == nil {
+			warning.Files = []string{file}
+		} else {
+			json.Unmarshal(warning.Value, &warning.Files)
+		}
+		r.UploadWarnings = append(r.UploadWarnings, warning)
+	}
+	return nil
+}
+
+// Unmarshal upload responses...
+type uploadResponse struct {
+	Upload UploadResult
+}
+
+// values converts the request in to API parameters, without the file.
+func (r *UploadRequest) values() (map[string]string, error) {
+	if r.Filename == "" {
+		return nil, errors.New("no filename supplied")
+	}
+	values := map[string]string{
+		"filename": r.Filename,
+	}
+	if r.Comment != "" {
+		values["comment"] = r.Comment
+	}
+	if r.Text != "" {
+		values["text"] = r.Text
+	}
+	if len(r.Tags) > 0 {
+		values["tags"] = strings.Join(r.Tags, "|")
+	}
+	if r.IgnoreWarnings {
+		values["ignorewarnings"] = "1"
+	}
+	if r.Watchlist != "" {
+		values["watchlist"] = r.Watchlist
+	}
+	return values, nil
+}
+
+// ChunkedUpload configures UploadChunked.
+//
+// See https://www.mediawiki.org/wiki/API:Upload#Chunked_uploading
+type ChunkedUpload struct {
+	// ChunkSize is the number of bytes sent with each request. Zero means
+	// 1 MiB. It must not exceed the wiki's $wgMaxUploadSize.
+	ChunkSize int64
+	// FileKey and Offset resume an interrupted upload. Set them to the
+	// last values passed to OnChunk; the first Offset bytes of the file
+	// are skipped.
+	FileKey string
+	Offset  int64
+	// OnChunk, if set, is called after every chunk is stashed with the file
+	// key and the offset of the next chunk, so that they can be saved.
+	OnChunk func(fileKey string, offset int64)
+}
+
+// UploadURL has the wiki download the file at fileURL and save it as
+// dstFilename, which avoids passing the file through the client. It
+// requires the upload_by_url right, and the wiki must allow copy uploads
+// from the domain of fileURL.
+//
+// If the wiki has warnings about the upload, such as the file already
+// existing, nothing is uploaded and the error satisfies
+// errors.Is(err, ErrUploadWarning). Use UploadFile with URL set to see the
+// warnings or to ignore them, and with Async set for files that take the
+// wiki a while to download.
+func (m *MWApi) UploadURL(dstFilename, fileURL string) error {
+	return m.UploadURLContext(context.Background(), dstFilename, fileURL)
+}
+
+// UploadURLContext is like UploadURL but uses ctx for the token lookup and
+// the upload request.
+func (m *MWApi) UploadURLContext(ctx context.Context, dstFilename, fileURL string) error {
+	_, err := m.UploadFileContext(ctx, &UploadRequest{Filename: dstFilename, URL: fileURL})
+	return err
+}
+
+// Upload a file
+//
+// This does a simple, but more error-prone upload. Large files should use
+// UploadChunked instead.
+//
+// The file is streamed to the server rather than read in to memory first.
+// If the request has to be sent again, for example because the edit token
+// expired, file must be an io.Seeker so that it can be rewound.
+//
+// If the wiki has warnings about the upload, such as the file already
+// existing, nothing is uploaded and the error satisfies
+// errors.Is(err, ErrUploadWarning). Use UploadFile to see the warnings or
+// to ignore them.
+//
+// Automatically retrieves an edit token if necessary.
+func (m *MWApi) Upload(dstFilename string, file io.Reader) error {
+	return m.UploadContext(context.Background(), dstFilename, file)
+}
+
+// UploadContext is like Upload but uses ctx for the token lookup and the
+// upload request.
+func (m *MWApi) UploadContext(ctx context.Context, dstFilename string, file io.Reader) error {
+	_, err := m.UploadFileContext(ctx, &UploadRequest{Filename: dstFilename, File: file})
+	return err
+}
+
+// UploadChunked uploads a file of size bytes in several smaller requests,
+// which is more reliable for large files. The chunks are stashed on the
+// server and then published as dstFilename in a final request.
+//
+// An interrupted upload can be resumed from the file key and offset that
+// were passed to opts.OnChunk, as long as the stash hasn't expired. opts
+// may be nil to use the defaults.
+//
+// If the wiki has warnings about the upload, such as the file already
+// existing, the file stays in the stash and the error satisfies
+// errors.Is(err, ErrUploadWarning). opts.FileKey is then set to the key of
+// the stashed file, which can be published anyway with UploadFile and
+// UploadRequest.FileKey.
+func (m *MWApi) UploadChunked(dstFilename string, file io.Reader, size int64, opts *ChunkedUpload) error {
+	return m.UploadChunkedContext(context.Background(), dstFilename, file, size, opts)
+}
+
+// UploadChunkedContext is like UploadChunked but uses ctx for every request.
+func (m *MWApi) UploadChunkedContext(ctx context.Context, dstFilename string, file io.Reader, size int64, opts *ChunkedUpload) error {
+	if opts == nil {
+		opts = &ChunkedUpload{}
+	}
+	request := &UploadRequest{
+		Filename: dstFilename,
+		File:     file,
+		Size:     size,
+		Chunked:  opts,
+	}
+	result, err := m.UploadFileContext(ctx, request)
+	if errors.Is(err, ErrUploadWarning) {
+		opts.FileKey, opts.Offset = result.FileKey, size
+	}
+	return err
+}
+
+// UploadFile uploads a file and returns the result.
+//
+// Unlike Upload, warnings such as the file already existing stop the upload
+// unless IgnoreWarnings is set. The result then lists the warnings and the
+// error satisfies errors.Is(err, ErrUploadWarning). When the server returns
+// a result other than Success both the result and an *APIError are
+// returned.
+//
+// Example:
+//
+//	result, err := client.UploadFile(&mediawiki.UploadRequest{
+//	    Filename: "Example.png",
+//	    File:     file,
+//	    Comment:  "THIS IS WHAT SHOWS UP IN THE LOG",
+//	    Text:     "THE TEXT OF THE FILE DESCRIPTION PAGE",
+//	})
+func (m *MWApi) UploadFile(request *UploadRequest) (*UploadResult, error) {
+	return m.UploadFileContext(context.Background(), request)
+}
+
+// UploadFileContext is like UploadFile but uses ctx for every request.
+func (m *MWApi) UploadFileContext(ctx context.Context, request *UploadRequest) (*UploadResult, error) {
+	fields, err := request.values()
+	if err != nil {
+		return nil, err
+	}
+
+	if request.OnDuplicate != DuplicateUpload && request.File != nil && request.FileKey == "" && request.URL == "" {
+		result, err := m.checkDuplicates(ctx, request)
+		if err != nil || result != nil {
+			return result, err
+		}
+	}
+
+	var body []byte
+	// The key of the stashed file that is published, if any.
+	var stashed string
+	switch {
+	case request.FileKey != "":
+		if request.Async {
+			fields["async"] = "1"
+		}
+		stashed = request.FileKey
+		body, err = m.publish(ctx, fields, request.FileKey)
+	case request.URL != "":
+		if request.Async {
+			fields["async"] = "1"
+		}
+		body, err = m.withToken(ctx, CSRFToken, func(token string) ([]byte, error) {
+			query := map[string]string{
+				"action": "upload",
+				"url":    request.URL,
+				"token":  token,
+			}
+			return m.APIContext(ctx, query, fields)
+		})
+	case request.File == nil:
+		return nil, errors.New("no file supplied")
+	case request.Chunked != nil:
+		var fileKey string
+		fileKey, err = m.stashChunks(ctx, request.Filename, request.File, request.Size, request.Chunked)
+		if err != nil {
+			return nil, err
+		}
+		if request.Async {
+			fields["async"] = "1"
+		}
+		stashed = fileKey
+		body, err = m.publish(ctx, fields, fileKey)
+	default:
+		upload := m.newUploadFile(request.Filename, request.File, 0, request.Size)
+		defer upload.close()
+		body, err = m.withToken(ctx, CSRFToken, func(token string) ([]byte, error) {
+			fields["token"] = token
+			return m.postMultipart(ctx, fields, "file", upload)
+		})
+	}
+	if err != nil {
+		return nil, err
+	}
+	result, err := parseUpload(body)
+	if errors.Is(err, ErrUploadWarning) && result.FileKey == "" {
+		// The file stays in the stash under the same key.
+		result.FileKey = stashed
+	}
+	if err != nil {
+		return result, err
+	}
+	return m.poll(ctx, result)
+}
+
+// Wait for an asynchronous upload to finish, checking on it with
+// checkstatus.
+func (m *MWApi) poll(ctx context.Context, result *UploadResult) (*UploadResult, error) {
+	delay := minPollInterval
+	for result.Result == "Poll" {
+		if result.FileKey == "" {
+			return nil, errors.New("no filekey returned for asynchronous upload")
+		}
+		err := sleep(ctx, delay)
+		if err != nil {
+			return nil, err
+		}
+		if delay *= 2; delay > maxPollInterval {
+			delay = maxPollInterval
+		}
+
+		fileKey := result.FileKey
+		body, err := m.withToken(ctx, CSRFToken, func(token string) ([]byte, error) {
+			query := map[string]string{
+				"action":      "upload",
+				"checkstatus": "1",
+				"filekey":     fileKey,
+				"token":       token,
+			}
+			return m.APIContext(ctx, query)
+		})
+		if err != nil {
+			return nil, err
+		}
+		result, err = parseUpload(body)
+		if err != nil {
+			return result, err
+		}
+	}
+	return result, nil
+}
+
+// Publish a file from the stash.
+func (m *MWApi) publish(ctx context.Context, fields map[string]string, fileKey string) ([]byte, error) {
+	return m.withToken(ctx, CSRFToken, func(token string) ([]byte, error) {
+		query := map[string]string{
+			"action":  "upload",
+			"filekey": fileKey,
+			"token":   token,
+		}
+		return m.APIContext(ctx, query, fields)
+	})
+}
+
+// Send the file to the stash chunk by chunk and return its file key.
+func (m *MWApi) stashChunks(ctx context.Context, dstFilename string, file io.Reader, size int64, opts *ChunkedUpload) (string, error) {
+	if size <= 0 {
+		return "", errors.New("chunked uploads need the size of the file")
+	}
+	chunkSize := opts.ChunkSize
+	if chunkSize <= 0 {
+		chunkSize = defaultChunkSize
+	}
+	fileKey, offset := opts.FileKey, opts.Offset
+
+	if offset > 0 {
+		err := skip(file, offset)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	chunk := make([]byte, chunkSize)
+	for offset < size {
+		n := chunkSize
+		if size-offset < n {
+			n = size - offset
+		}
+		_, err := io.ReadFull(file, chunk[:n])
+		if err != nil {
+			return "", err
+		}
+
+		upload := m.newUploadFile(dstFilename, bytes.NewReader(chunk[:n]), offset, n)
+		body, err := m.withToken(ctx, CSRFToken, func(token string) ([]byte, error) {
+			fields := map[string]string{
+				"filename": dstFilename,
+				"filesize": strconv.FormatInt(size, 10),
+				"offset":   strconv.FormatInt(offset, 10),
+				"stash":    "1",
+				"token":    token,
+			}
+			if fileKey != "" {
+				fields["filekey"] = fileKey
+			}
+			return m.postMultipart(ctx, fields, "chunk", upload)
+		})
+		upload.close()
+		if err != nil {
+			return "", err
+		}
+
+		var response uploadResponse
+		err = json.Unmarshal(body, &response)
+		if err != nil {
+			return "", err
+		}
+		switch response.Upload.Result {
+		case "Continue":
+			offset = response.Upload.offset
+		case "Success":
+			offset = size
+		default:
+			return "", &APIError{Code: response.Upload.Result, Module: "upload"}
+		}
+		if response.Upload.FileKey != "" {
+			fileKey = response.Upload.FileKey
+		}
+		if opts.OnChunk != nil {
+			opts.OnChunk(fileKey, offset)
+		}
+	}
+	if fileKey == "" {
+		return "", errors.New("no filekey returned for chunked upload")
+	}
+	return fileKey, nil
+}
+
+// Skip the first n bytes of r.
+func skip(r io.Reader, n int64) error {
+	if seeker, ok := r.(io.Seeker); ok {
+		_, err := seeker.Seek(n, io.SeekCurrent)
+		return err
+	}
+	_, err := io.CopyN(ioutil.Discard, r, n)
+	return err
+}
+
+// Check the result of an upload request.
+func parseUpload(body []byte) (*UploadResult, error) {
+	var response uploadResponse
+	err := json.Unmarshal(body, &response)
+	if err != nil {
+		return nil, err
+	}
+	result := &response.Upload
+	result.Warnings = parseWarnings(body)
+
+	switch result.Result {
+	case "Success", "Poll":
+		return result, nil
+	case "Warning":
+		codes := make([]string, len(result.UploadWarnings))
+		for i, warning := range result.UploadWarnings {
+			codes[i] = warning.Code
+		}
+		return result, &APIError{Code: result.Result, Info: strings.Join(codes, ", "), Module: "upload"}
+	default:
+		return result, &APIError{Code: result.Result, Module: "upload"}
+	}
+}
+
+// A file sent with upload requests. The request body is streamed from r
+// through a pipe, so the file is never held in memory, and r is rewound
+// when a request is sent again. When the size of the file is known the
+// request is sent with a Content-Length, as some servers don't accept
+// chunked request bodies.
+type uploadFile struct {
+	name     string
+	r        io.Reader
+	progress func(filename string, sent int64)
+	// The position of r when the upload started, or -1 if r can't seek,
+	// and the offset of that position within the whole file.
+	start int64
+	base  int64
+	// The number of bytes that will be read from r, or -1 if unknown.
+	size int64
+	// The body of the last request and a channel closed once it has
+	// been written.
+	pipe *io.PipeReader
+	done chan struct{}
+}
+
+// Prepare r to be uploaded as filename. base is the offset of r within the
+// whole file, which is reported to OnUploadProgress. size is the number of
+// bytes left in r, zero if unknown, which is only used if r can't seek.
+func (m *MWApi) newUploadFile(filename string, r io.Reader, base, size int64) *uploadFile {
+	file := &uploadFile{
+		name:     filename,
+		r:        r,
+		progress: m.OnUploadProgress,
+		start:    -1,
+		base:     base,
+		size:     -1,
+	}
+	if size > 0 {
+		file.size = size
+	}
+	if seeker, ok := r.(io.Seeker); ok {
+		start, err := seeker.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return file
+		}
+		end, err := seeker.Seek(0, io.SeekEnd)
+		if err != nil {
+			return file
+		}
+		_, err = seeker.Seek(start, io.SeekStart)
+		if err == nil {
+			file.start = start
+			file.size = end - start
+		}
+	}
+	return file
+}
+
+// Return a multipart request body with the given fields followed by the
+// file in fileField, its length or -1 if unknown, and its content type.
+func (f *uploadFile) body(fields map[string]string, fileField string) (io.Reader, int64, string, error) {
+	if f.done != nil {
+		// Wait for the last request to let go of r before rewinding it.
+		f.close()
+		if f.start < 0 {
+			return nil, 0, "", errors.New("upload can't be sent again, the file is not an io.Seeker")
+		}
+		_, err := f.r.(io.Seeker).Seek(f.start, io.SeekStart)
+		if err != nil {
+			return nil, 0, "", err
+		}
+	}
+
+	// Everything but the file is small, so it is prepared up front to
+	// work out the length of the body.
+	header := &bytes.Buffer{}
+	form := multipart.NewWriter(header)
+	for key, value := range fields {
+		err := form.WriteField(key, value)
+		if err != nil {
+			return nil, 0, "", err
+		}
+	}
+	_, err := form.CreateFormFile(fileField, f.name)
+	if err != nil {
+		return nil, 0, "", err
+	}
+	// This is what form.Close writes after the last part.
+	trailer := "\r\n--" + form.Boundary() + "--\r\n"
+	length := int64(-1)
+	if f.size >= 0 {
+		length = int64(header.Len()) + f.size + int64(len(trailer))
+	}
+
+	reader, writer := io.Pipe()
+	done := make(chan struct{})
+	f.pipe, f.done = reader, done
+	go func() {
+		defer close(done)
+		writer.CloseWithError(f.write(writer, header.Bytes(), trailer))
+	}()
+	return reader, length, form.FormDataContentType(), nil
+}
+
+// Write the multipart form around the contents of the file.
+func (f *uploadFile) write(w io.Writer, header []byte, trailer string) error {
+	_, err := w.Write(header)
+	if err != nil {
+		return err
+	}
+	var src io.Reader = f.r
+	if f.progress != nil {
+		src = &progressReader{r: f.r, file: f, sent: f.base}
+	}
+	n, err := io.Copy(w, src)
+	if err != nil {
+		return err
+	}
+	if f.size >= 0 && n != f.size {
+		return errors.New("file size doesn't match the size given for the upload")
+	}
+	_, err = io.WriteString(w, trailer)
+	return err
+}
+
+// Abort the request body if it is still being written and wait until the
+// file is no longer read from.
+func (f *uploadFile) close() {
+	if f.done == nil {
+		return
+	}
+	f.pipe.Close()
+	<-f.done
+}
+
+// Report the progress of reads from r.
+type progressReader struct {
+	r    io.Reader
+	file *uploadFile
+	sent int64
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	if n > 0 {
+		p.sent += int64(n)
+		p.file.progress(p.file.name, p.sent)
+	}
+	return n, err
+}
+
+// Send a single multipart upload request with the given fields and the
+// file in fileField.
+func (m *MWApi) postMultipart(ctx context.Context, fields map[string]string, fileField string, file *uploadFile) ([]byte, error) {
+	query := map[string]string{
+		"action": "upload",
+		"format": m.format,
+	}
+	for key, value := range fields {
+		query[key] = value
+	}
+	if maxlag := m.maxLag(); maxlag != "" {
+		query["maxlag"] = maxlag
+	}
+	if m.Assert != "" {
+		query["assert"] = m.Assert
+	}
+
+	return m.do(ctx, "upload", nil, func() (*http.Request, error) {
+		body, length, contentType, err := file.body(query, fileField)
+		if err != nil {
+			return nil, err
+		}
+		request, err := http.NewRequestWithContext(ctx, "POST", m.url.String(), body)
+		if err != nil {
+			return nil, err
+		}
+		// Without a length the body is sent with chunked encoding.
+		if length >= 0 {
+			request.ContentLength = length
+		}
+		request.Header.Set("Content-Type", contentType)
+		return request, nil
+	})
+}
@@ -0,0 +1,466 @@
+package mediawiki
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// A wiki that accepts chunked uploads in to its stash.
+type chunkServer struct {
+	t         *testing.T
+	stash     []byte
+	committed string
+	chunks    int
+	// Fail the chunk request with this number, if non-zero.
+	failChunk int
+	// The response to the final request, if not Success.
+	commit string
+}
+
+func (s *chunkServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/") {
+		err := r.ParseForm()
+		if err != nil {
+			panic(err)
+		}
+		if r.Form.Get("meta") == "tokens" {
+			fmt.Fprintln(w, editTokenReponse)
+			return
+		}
+		if r.Form.Get("filekey") != "KEY" || r.Form.Get("filename") != "big.bin" {
+			s.t.Errorf("Bad commit request: %v", r.Form)
+		}
+		if s.commit != "" {
+			fmt.Fprintln(w, s.commit)
+			return
+		}
+		s.committed = string(s.stash)
+		fmt.Fprintln(w, `{"upload":{"result":"Success","filename":"Big.bin"}}`)
+		return
+	}
+
+	err := r.ParseMultipartForm(int64(10000))
+	if err != nil {
+		panic(err)
+	}
+	s.chunks++
+	if s.chunks == s.failChunk {
+		http.Error(w, "Gateway Timeout", http.StatusGatewayTimeout)
+		return
+	}
+	values := r.MultipartForm.Value
+	if values["stash"][0] != "1" || values["filesize"][0] != "26" {
+		s.t.Errorf("Bad chunk request: %v", values)
+	}
+	offset, _ := strconv.Atoi(values["offset"][0])
+	if offset != len(s.stash) {
+		s.t.Errorf("Chunk sent for offset %d, expected %d", offset, len(s.stash))
+	}
+	if offset > 0 && values["filekey"][0] != "KEY" {
+		s.t.Errorf("filekey was not sent with a later chunk: %v", values)
+	}
+	chunk, err := r.MultipartForm.File["chunk"][0].Open()
+	if err != nil {
+		panic(err)
+	}
+	defer chunk.Close()
+	contents, _ := ioutil.ReadAll(chunk)
+	s.stash = append(s.stash, contents...)
+	if len(s.stash) == 26 {
+		fmt.Fprintln(w, `{"upload":{"result":"Success","filekey":"KEY"}}`)
+		return
+	}
+	fmt.Fprintf(w, `{"upload":{"result":"Continue","filekey":"KEY","offset":%d}}`, len(s.stash))
+}
+
+func TestUploadChunked(t *testing.T) {
+	server := &chunkServer{t: t}
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+	client, err := New(ts.URL, "TESTING")
+	if err != nil {
+		t.Fatalf("Error creating client: %s", err)
+	}
+
+	var offsets []int64
+	opts := &ChunkedUpload{
+		ChunkSize: 10,
+		OnChunk: func(fileKey string, offset int64) {
+			if fileKey != "KEY" {
+				t.Errorf("Wrong file key: %s", fileKey)
+			}
+			offsets = append(offsets, offset)
+		},
+	}
+	err = client.UploadChunked("big.bin", strings.NewReader("abcdefghijklmnopqrstuvwxyz"), 26, opts)
+	if err != nil {
+		t.Fatalf("Chunked upload failed: %s", err)
+	}
+	if server.committed != "abcdefghijklmnopqrstuvwxyz" {
+		t.Errorf("Wrong file committed: %q", server.committed)
+	}
+	if len(offsets) != 3 || offsets[0] != 10 || offsets[1] != 20 || offsets[2] != 26 {
+		t.Errorf("Wrong offsets reported: %v", offsets)
+	}
+}
+
+func TestUploadChunkedResume(t *testing.T) {
+	server := &chunkServer{t: t, failChunk: 2}
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+	client, err := New(ts.URL, "TESTING")
+	if err != nil {
+		t.Fatalf("Error creating client: %s", err)
+	}
+
+	var fileKey string
+	var offset int64
+	opts := &ChunkedUpload{
+		ChunkSize: 10,
+		OnChunk: func(k string, o int64) {
+			fileKey, offset = k, o
+		},
+	}
+	err = client.UploadChunked("big.bin", strings.NewReader("abcdefghijklmnopqrstuvwxyz"), 26, opts)
+	if err == nil {
+		t.Fatal("Chunked upload did not fail")
+	}
+	if fileKey != "KEY" || offset != 10 {
+		t.Fatalf("Wrong resume point: %s %d", fileKey, offset)
+	}
+
+	// Resume with a fresh reader that can't seek, as if the process had
+	// restarted and was reading from a pipe.
+	opts = &ChunkedUpload{ChunkSize: 10, FileKey: fileKey, Offset: offset}
+	err = client.UploadChunked("big.bin", struct{ io.Reader }{strings.NewReader("abcdefghijklmnopqrstuvwxyz")}, 26, opts)
+	if err != nil {
+		t.Fatalf("Resumed upload failed: %s", err)
+	}
+	if server.committed != "abcdefghijklmnopqrstuvwxyz" {
+		t.Errorf("Wrong file committed: %q", server.committed)
+	}
+}
+
+func TestUploadChunkedWarning(t *testing.T) {
+	server := &chunkServer{t: t, commit: `{"upload":{"result":"Warning","warnings":{"exists":"Big.bin"}}}`}
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+	client, err := New(ts.URL, "TESTING")
+	if err != nil {
+		t.Fatalf("Error creating client: %s", err)
+	}
+
+	opts := &ChunkedUpload{ChunkSize: 10}
+	err = client.UploadChunked("big.bin", strings.NewReader("abcdefghijklmnopqrstuvwxyz"), 26, opts)
+	if !errors.Is(err, ErrUploadWarning) {
+		t.Fatalf("Expected an upload warning, got %v", err)
+	}
+	if opts.FileKey != "KEY" || opts.Offset != 26 {
+		t.Errorf("Stashed file was not reported: %s %d", opts.FileKey, opts.Offset)
+	}
+	if server.committed != "" {
+		t.Errorf("File was published despite the warning")
+	}
+}
+
+func TestUploadProgress(t *testing.T) {
+	contents := strings.Repeat("0123456789", 10000)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/") {
+			fmt.Fprintln(w, editTokenReponse)
+			return
+		}
+		// The length is known because the file can seek.
+		if r.ContentLength <= int64(len(contents)) {
+			t.Errorf("Upload was sent with a length of %d", r.ContentLength)
+		}
+		err := r.ParseMultipartForm(int64(1 << 20))
+		if err != nil {
+			panic(err)
+		}
+		if r.MultipartForm.File["file"][0].Size != int64(len(contents)) {
+			t.Errorf("Wrong file size received: %d", r.MultipartForm.File["file"][0].Size)
+		}
+		fmt.Fprintln(w, `{"upload":{"result":"Success"}}`)
+	}))
+	defer ts.Close()
+	client, err := New(ts.URL, "TESTING")
+	if err != nil {
+		t.Fatalf("Error creating client: %s", err)
+	}
+
+	var last int64
+	client.OnUploadProgress = func(filename string, sent int64) {
+		if filename != "big.txt" {
+			t.Errorf("Progress reported for %s", filename)
+		}
+		if sent <= last {
+			t.Errorf("Progress went from %d to %d", last, sent)
+		}
+		last = sent
+	}
+	err = client.Upload("big.txt", strings.NewReader(contents))
+	if err != nil {
+		t.Fatalf("Upload failed: %s", err)
+	}
+	if last != int64(len(contents)) {
+		t.Errorf("Progress stopped at %d of %d bytes", last, len(contents))
+	}
+}
+
+func TestUploadContentLength(t *testing.T) {
+	var lengths []int64
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/") {
+			fmt.Fprintln(w, editTokenReponse)
+			return
+		}
+		// The upload with the wrong size is cut short.
+		err := r.ParseMultipartForm(int64(10000))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		lengths = append(lengths, r.ContentLength)
+		fmt.Fprintln(w, `{"upload":{"result":"Success"}}`)
+	}))
+	defer ts.Close()
+	client, err := New(ts.URL, "TESTING")
+	if err != nil {
+		t.Fatalf("Error creating client: %s", err)
+	}
+
+	// Without a size, a file that can't seek is sent with chunked
+	// encoding.
+	_, err = client.UploadFile(&UploadRequest{Filename: "stuff", File: struct{ io.Reader }{strings.NewReader("stuff")}})
+	if err != nil {
+		t.Fatalf("Upload failed: %s", err)
+	}
+	_, err = client.UploadFile(&UploadRequest{Filename: "stuff", File: struct{ io.Reader }{strings.NewReader("stuff")}, Size: 5})
+	if err != nil {
+		t.Fatalf("Upload failed: %s", err)
+	}
+	_, err = client.UploadFile(&UploadRequest{Filename: "stuff", File: strings.NewReader("stuff")})
+	if err != nil {
+		t.Fatalf("Upload failed: %s", err)
+	}
+	if len(lengths) != 3 || lengths[0] != -1 || lengths[1] <= 5 || lengths[2] != lengths[1] {
+		t.Errorf("Wrong lengths: %v", lengths)
+	}
+
+	_, err = client.UploadFile(&UploadRequest{Filename: "stuff", File: struct{ io.Reader }{strings.NewReader("stuff")}, Size: 4})
+	if err == nil {
+		t.Errorf("Upload with the wrong size didn't fail")
+	}
+}
+
+func TestUploadNotSeekable(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/") {
+			fmt.Fprintln(w, editTokenReponse)
+			return
+		}
+		fmt.Fprintln(w, badtoken)
+	}))
+	defer ts.Close()
+	client, err := New(ts.URL, "TESTING")
+	if err != nil {
+		t.Fatalf("Error creating client: %s", err)
+	}
+
+	err = client.Upload("stuff", struct{ io.Reader }{strings.NewReader("stuff")})
+	if err == nil || !strings.Contains(err.Error(), "io.Seeker") {
+		t.Fatalf("Expected an error about resending the file, got %v", err)
+	}
+}
+
+const uploadwarning = `{"upload":{"result":"Warning","warnings":{"exists":"Example.png","duplicate":["Copy.png","Other copy.png"]},"filekey":"KEY"}}`
+
+const uploadsuccess = `{"upload":{"result":"Success","filename":"Example.png","imageinfo":{"timestamp":"2016-05-01T10:00:00Z","user":"Bot","size":5,"width":1,"height":1,"url":"http://example.com/Example.png","sha1":"2b7d3d8f4b0b0e3e0b58b39d44a2d1b1d3c4a4f2","mime":"image/png"}}}`
+
+func TestUploadFileWarnings(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/") {
+			err := r.ParseForm()
+			if err != nil {
+				panic(err)
+			}
+			if r.Form.Get("meta") == "tokens" {
+				fmt.Fprintln(w, editTokenReponse)
+				return
+			}
+			if r.Form.Get("filekey") != "KEY" || r.Form.Get("ignorewarnings") != "1" ||
+				r.Form.Get("comment") != "Upload summary" {
+				t.Errorf("Bad publish request: %v", r.Form)
+			}
+			fmt.Fprintln(w, uploadsuccess)
+			return
+		}
+
+		err := r.ParseMultipartForm(int64(10000))
+		if err != nil {
+			panic(err)
+		}
+		values := r.MultipartForm.Value
+		if values["comment"][0] != "Upload summary" || values["text"][0] != "Description" ||
+			values["tags"][0] != "one|two" || values["watchlist"][0] != WatchlistWatch {
+			t.Errorf("Bad upload request: %v", values)
+		}
+		if _, ok := values["ignorewarnings"]; ok {
+			t.Errorf("ignorewarnings was sent")
+		}
+		fmt.Fprintln(w, uploadwarning)
+	}))
+	defer ts.Close()
+	client, err := New(ts.URL, "TESTING")
+	if err != nil {
+		t.Fatalf("Error creating client: %s", err)
+	}
+
+	request := &UploadRequest{
+		Filename:  "Example.png",
+		File:      strings.NewReader("stuff"),
+		Comment:   "Upload summary",
+		Text:      "Description",
+		Tags:      []string{"one", "two"},
+		Watchlist: WatchlistWatch,
+	}
+	result, err := client.UploadFile(request)
+	if !errors.Is(err, ErrUploadWarning) {
+		t.Fatalf("Expected an upload warning, got %v", err)
+	}
+	if result.FileKey != "KEY" || len(result.UploadWarnings) != 2 {
+		t.Fatalf("Wrong result: %+v", result)
+	}
+	duplicate, exists := result.UploadWarnings[0], result.UploadWarnings[1]
+	if duplicate.Code != UploadWarningDuplicate || len(duplicate.Files) != 2 || duplicate.Files[1] != "Other copy.png" {
+		t.Errorf("Wrong duplicate warning: %+v", duplicate)
+	}
+	if exists.Code != UploadWarningExists || len(exists.Files) != 1 || exists.Files[0] != "Example.png" {
+		t.Errorf("Wrong exists warning: %+v", exists)
+	}
+
+	// Publish the stashed file anyway.
+	request = &UploadRequest{
+		Filename:       "Example.png",
+		FileKey:        result.FileKey,
+		Comment:        "Upload summary",
+		IgnoreWarnings: true,
+	}
+	result, err = client.UploadFile(request)
+	if err != nil {
+		t.Fatalf("Publishing the stashed file failed: %s", err)
+	}
+	if result.ImageInfo == nil || result.ImageInfo.Size != 5 || result.ImageInfo.Mime != "image/png" {
+		t.Errorf("Wrong imageinfo: %+v", result.ImageInfo)
+	}
+}
+
+func TestUploadWarning(t *testing.T) {
+	test := BuildUp(uploadwarning, t)
+	defer test.TearDown()
+	test.client.tokens[CSRFToken] = "ASDF"
+
+	err := test.client.Upload("stuff", strings.NewReader("stuff"))
+	if !errors.Is(err, ErrUploadWarning) {
+		t.Fatalf("Expected an upload warning, got %v", err)
+	}
+}
+
+func TestUploadURL(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		err := r.ParseForm()
+		if err != nil {
+			panic(err)
+		}
+		if r.Form.Get("meta") == "tokens" {
+			fmt.Fprintln(w, editTokenReponse)
+			return
+		}
+		if r.Form.Get("url") != "http://assets.example.com/Example.png" || r.Form.Get("filename") != "Example.png" {
+			t.Errorf("Bad upload request: %v", r.Form)
+		}
+		if r.Form.Get("async") != "" {
+			t.Errorf("Synchronous upload was sent with async")
+		}
+		fmt.Fprintln(w, uploadsuccess)
+	}))
+	defer ts.Close()
+	client, err := New(ts.URL, "TESTING")
+	if err != nil {
+		t.Fatalf("Error creating client: %s", err)
+	}
+
+	err = client.UploadURL("Example.png", "http://assets.example.com/Example.png")
+	if err != nil {
+		t.Fatalf("Upload by URL failed: %s", err)
+	}
+}
+
+func TestUploadURLWarning(t *testing.T) {
+	test := BuildUp(uploadwarning, t)
+	defer test.TearDown()
+	test.client.tokens[CSRFToken] = "ASDF"
+
+	err := test.client.UploadURL("Example.png", "http://assets.example.com/Example.png")
+	if !errors.Is(err, ErrUploadWarning) {
+		t.Fatalf("Expected an upload warning, got %v", err)
+	}
+}
+
+func TestUploadURLAsync(t *testing.T) {
+	checks := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		err := r.ParseForm()
+		if err != nil {
+			panic(err)
+		}
+		switch {
+		case r.Form.Get("meta") == "tokens":
+			fmt.Fprintln(w, editTokenReponse)
+		case r.Form.Get("checkstatus") == "1":
+			if r.Form.Get("filekey") != "KEY" {
+				t.Errorf("Bad status request: %v", r.Form)
+			}
+			checks++
+			if checks < 2 {
+				fmt.Fprintln(w, `{"upload":{"result":"Poll","stage":"fetching","filekey":"KEY"}}`)
+				return
+			}
+			fmt.Fprintln(w, uploadsuccess)
+		default:
+			if r.Form.Get("async") != "1" {
+				t.Errorf("Bad upload request: %v", r.Form)
+			}
+			fmt.Fprintln(w, `{"upload":{"result":"Poll","stage":"queued","filekey":"KEY"}}`)
+		}
+	}))
+	defer ts.Close()
+	client, err := New(ts.URL, "TESTING")
+	if err != nil {
+		t.Fatalf("Error creating client: %s", err)
+	}
+
+	request := &UploadRequest{
+		Filename: "Example.png",
+		URL:      "http://assets.example.com/Example.png",
+		Async:    true,
+	}
+	result, err := client.UploadFile(request)
+	if err != nil {
+		t.Fatalf("Asynchronous upload failed: %s", err)
+	}
+	if checks != 2 {
+		t.Errorf("Expected 2 status checks, got %d", checks)
+	}
+	if result.Result != "Success" || result.Filename != "Example.png" {
+		t.Errorf("Wrong result: %+v", result)
+	}
+}
@@ -0,0 +1,136 @@
+//
+// Copyright 2016 James McGuire
+//
+// This code is covered under the MIT License
+// Please refer to the LICENSE file in the root of this
+// repository for any information.
+
+package mediawiki
+
+import (
+	"bytes"
+	"encoding/json"
+	"sort"
+	"strings"
+)
+
+// Warning is a single warning returned by the MediaWiki API alongside an
+// otherwise successful response, for example when a deprecated parameter
+// is used or when results are truncated.
+type Warning struct {
+	// Module is the API module that generated the warning.
+	Module string
+	// Info is the text of the warning.
+	Info string
+	// Code identifies the warning. It is only set when the request uses an
+	// errorformat other than bc.
+	Code string
+}
+
+// Warnings is the list of warnings included in a response.
+//
+// By default MediaWiki returns warnings as an object keyed by module name;
+// this is flattened in to one Warning per line of text, sorted by module.
+// With any other errorformat they are returned as a list, which is kept in
+// order. Warnings in a shape that isn't recognised are left out.
+type Warnings []Warning
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (w *Warnings) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	switch {
+	case bytes.HasPrefix(data, []byte("[")):
+		return w.unmarshalList(data)
+	case !bytes.HasPrefix(data, []byte("{")):
+		*w = Warnings{}
+		return nil
+	}
+
+	var modules map[string]struct {
+		// The legacy format puts the text in '*', formatversion=2 uses
+		// 'warnings'.
+		Star     string `json:"*"`
+		Warnings string
+	}
+	err := json.Unmarshal(data, &modules)
+	if err != nil {
+		*w = Warnings{}
+		return nil
+	}
+
+	names := make([]string, 0, len(modules))
+	for name := range modules {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	warnings := Warnings{}
+	for _, name := range names {
+		text := modules[name].Star
+		if text == "" {
+			text = modules[name].Warnings
+		}
+		// Several warnings from one module are separated by newlines.
+		for _, line := range strings.Split(text, "\n") {
+			if line != "" {
+				warnings = append(warnings, Warning{Module: name, Info: line})
+			}
+		}
+	}
+	*w = warnings
+	return nil
+}
+
+// Decode the list of warnings used by every errorformat but bc.
+func (w *Warnings) unmarshalList(data []byte) error {
+	var list []struct {
+		Code   string
+		Module string
+		// plaintext and wikitext use 'text', or '*' with formatversion=1,
+		// and html uses 'html'.
+		Text string
+		Star string `json:"*"`
+		HTML string
+	}
+	warnings := Warnings{}
+	if json.Unmarshal(data, &list) != nil {
+		*w = warnings
+		return nil
+	}
+	for _, item := range list {
+		text := item.Text
+		if text == "" {
+			text = item.Star
+		}
+		if text == "" {
+			text = item.HTML
+		}
+		// errorformat=none only has the code.
+		if text == "" {
+			text = item.Code
+		}
+		warnings = append(warnings, Warning{Module: item.Module, Info: text, Code: item.Code})
+	}
+	*w = warnings
+	return nil
+}
+
+// Returns any warnings in a raw response. Responses that can't be parsed
+// simply have no warnings.
+func parseWarnings(body []byte) Warnings {
+	var response struct {
+		Warnings Warnings
+	}
+	json.Unmarshal(body, &response)
+	return response.Warnings
+}
+
+// Pass any warnings in a response to the OnWarning hook.
+func (m *MWApi) handleWarnings(body []byte) {
+	if m.OnWarning == nil {
+		return
+	}
+	for _, warning := range parseWarnings(body) {
+		m.OnWarning(warning)
+	}
+}
@@ -0,0 +1,104 @@
+package mediawiki
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+const (
+	warningsLegacy = `{"warnings":{"main":{"*":"Unrecognized parameter: foo.\nSecond warning."},"info":{"*":"The intoken parameter has been deprecated."}},"query":{"pages":{"1":{"pageid":1,"title":"Main Page"}}}}`
+	warningsV2     = `{"warnings":{"main":{"warnings":"Unrecognized parameter: foo."}},"upload":{"result":"Success"}}`
+	warningsList   = `{"warnings":[{"code":"unrecognizedparams","module":"main","text":"Unrecognized parameter: foo."},{"code":"deprecation","module":"info","html":"The <var>intoken</var> parameter has been deprecated."}],"query":{"pages":{"1":{"pageid":1,"title":"Main Page"}}}}`
+)
+
+func TestWarningsUnmarshal(t *testing.T) {
+	warnings := parseWarnings([]byte(warningsLegacy))
+	expected := Warnings{
+		{Module: "info", Info: "The intoken parameter has been deprecated."},
+		{Module: "main", Info: "Unrecognized parameter: foo."},
+		{Module: "main", Info: "Second warning."},
+	}
+	if len(warnings) != len(expected) {
+		t.Fatalf("Expected %d warnings, got %v", len(expected), warnings)
+	}
+	for i := range expected {
+		if warnings[i] != expected[i] {
+			t.Errorf("Warning %d: expected %v, got %v", i, expected[i], warnings[i])
+		}
+	}
+
+	warnings = parseWarnings([]byte(warningsV2))
+	if len(warnings) != 1 || warnings[0].Info != "Unrecognized parameter: foo." {
+		t.Errorf("formatversion=2 warnings not parsed: %v", warnings)
+	}
+
+	warnings = parseWarnings([]byte(warningsList))
+	expected = Warnings{
+		{Module: "main", Info: "Unrecognized parameter: foo.", Code: "unrecognizedparams"},
+		{Module: "info", Info: "The <var>intoken</var> parameter has been deprecated.", Code: "deprecation"},
+	}
+	if len(warnings) != len(expected) {
+		t.Fatalf("Expected %d warnings, got %v", len(expected), warnings)
+	}
+	for i := range expected {
+		if warnings[i] != expected[i] {
+			t.Errorf("Warning %d: expected %v, got %v", i, expected[i], warnings[i])
+		}
+	}
+
+	var response Response
+	err := json.Unmarshal([]byte(warningsList), &response)
+	if err != nil {
+		t.Fatalf("Error decoding a response with a list of warnings: %s", err)
+	}
+	if len(response.Warnings) != 2 || len(response.Query.Pages) != 1 {
+		t.Errorf("Response not decoded: %+v", response)
+	}
+
+	err = json.Unmarshal([]byte(`{"warnings":"unexpected","query":{"pages":{}}}`), &response)
+	if err != nil || len(response.Warnings) != 0 {
+		t.Errorf("Unrecognised warnings were not skipped: %v, %v", err, response.Warnings)
+	}
+}
+
+func TestWarningsHook(t *testing.T) {
+	test := BuildUp(warningsLegacy, t)
+	defer test.TearDown()
+	var received []Warning
+	test.client.OnWarning = func(w Warning) {
+		received = append(received, w)
+	}
+
+	iter := test.client.Query(map[string]string{"titles": "Main Page"})
+	if !iter.Next() {
+		t.Fatalf("Query failed: %s", iter.Err())
+	}
+	if len(received) != 3 {
+		t.Errorf("OnWarning was not called for every warning: %v", received)
+	}
+	response, err := iter.Response()
+	if err != nil {
+		t.Fatalf("Error decoding response: %s", err)
+	}
+	if len(response.Warnings) != 3 {
+		t.Errorf("Response did not expose warnings: %v", response.Warnings)
+	}
+}
+
+func TestWarningsHookUpload(t *testing.T) {
+	test := BuildUp(warningsV2, t)
+	defer test.TearDown()
+	test.client.tokens[CSRFToken] = "asdf"
+	var received []Warning
+	test.client.OnWarning = func(w Warning) {
+		received = append(received, w)
+	}
+	err := test.client.Upload("stuff", strings.NewReader("stuff"))
+	if err != nil {
+		t.Fatalf("Error uploading: %s", err)
+	}
+	if len(received) != 1 || received[0].Module != "main" {
+		t.Errorf("OnWarning was not called for upload warnings: %v", received)
+	}
+}